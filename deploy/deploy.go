@@ -0,0 +1,501 @@
+// Package deploy pushes a build produced by the main package's doBuild
+// out to a fleet of remote hosts over SSH: it streams the build
+// artifacts and config via SFTP into a staging directory and then runs
+// the quickpackage binary itself on the remote host to install them,
+// so the remote side follows exactly the same install logic as a local
+// run.
+package deploy
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Target describes one remote host to deploy to and how to reach it.
+type Target struct {
+	Host        string
+	Port        int
+	User        string
+	KeyPath     string
+	UseAgent    bool
+	Become      bool
+	BecomeUser  string
+	Environment map[string]string
+
+	// BinaryPath overrides the local quickpackage binary InstallOn
+	// uploads to this host, for fleets where the control host's OS/arch
+	// doesn't match the target (e.g. deploying from a macOS laptop to a
+	// Linux fleet). When set, InstallOn skips its same-OS/arch check and
+	// trusts the caller to have pointed it at a binary built for Host.
+	BinaryPath string
+}
+
+// Strategy controls how targets are grouped into batches. A zero
+// Strategy deploys to every target at once.
+type Strategy struct {
+	Rolling   bool
+	BatchSize string // e.g. "25%" or "5"; ignored unless Rolling is set
+}
+
+// HostResult is the outcome of running a deploy function against one target.
+type HostResult struct {
+	Host string
+	Err  error
+}
+
+// Report summarises a Deploy run across every target.
+type Report struct {
+	Results []HostResult
+}
+
+// Failed returns the subset of Results that errored.
+func (r Report) Failed() []HostResult {
+	var out []HostResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// OK reports whether every target succeeded.
+func (r Report) OK() bool {
+	return len(r.Failed()) == 0
+}
+
+// Deploy runs fn against every target, grouped into batches sized by
+// strategy and bounded to parallel concurrent connections within a
+// batch. For a rolling strategy, Deploy stops before starting the next
+// batch if any host in the current one failed, so a bad rollout never
+// reaches the whole fleet.
+func Deploy(targets []Target, parallel int, strategy Strategy, fn func(Target) error) Report {
+	var report Report
+	for _, batch := range batchTargets(targets, strategy) {
+		results := runBatch(batch, parallel, fn)
+		report.Results = append(report.Results, results...)
+
+		if strategy.Rolling && (Report{Results: results}).Failed() != nil {
+			break
+		}
+	}
+	return report
+}
+
+func runBatch(batch []Target, parallel int, fn func(Target) error) []HostResult {
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	results := make([]HostResult, len(batch))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i, t := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = HostResult{Host: t.Host, Err: fn(t)}
+		}(i, t)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func batchTargets(targets []Target, strategy Strategy) [][]Target {
+	if !strategy.Rolling || strategy.BatchSize == "" {
+		return [][]Target{targets}
+	}
+
+	size := resolveBatchSize(strategy.BatchSize, len(targets))
+	var batches [][]Target
+	for i := 0; i < len(targets); i += size {
+		end := i + size
+		if end > len(targets) {
+			end = len(targets)
+		}
+		batches = append(batches, targets[i:end])
+	}
+	return batches
+}
+
+func resolveBatchSize(spec string, total int) int {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasSuffix(spec, "%") {
+		pct, err := strconv.Atoi(strings.TrimSuffix(spec, "%"))
+		if err != nil || pct <= 0 {
+			return total
+		}
+		size := total * pct / 100
+		if size < 1 {
+			size = 1
+		}
+		return size
+	}
+
+	n, err := strconv.Atoi(spec)
+	if err != nil || n <= 0 {
+		return total
+	}
+	return n
+}
+
+// InstallOn uploads buildDir and configPath into a staging directory
+// on t, along with the quickpackage binary at binaryPath, then runs
+// `quickpackage install` there. Install files sourced `from: "cwd"` in
+// the config must already be reachable inside buildDir, since deploy
+// does not ship the whole project tree to the remote host.
+//
+// Staging happens under a directory relative to the SSH login user's
+// default directory (normally their home), never under an InstallPath
+// like /opt, so the upload succeeds over plain SFTP even when Become is
+// set to sudo into the install itself.
+func InstallOn(t Target, binaryPath, buildDir, configPath, appName string) error {
+	client, err := t.dial()
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.Host, err)
+	}
+	defer client.Close()
+
+	if t.BinaryPath == "" {
+		if err := checkArch(client, t.Host); err != nil {
+			return fmt.Errorf("%s: %w", t.Host, err)
+		}
+	}
+
+	remoteDir := stagingDir(appName)
+	if err := uploadDir(client, buildDir, filepath.Join(remoteDir, "build")); err != nil {
+		return fmt.Errorf("%s: failed to upload build artifacts: %w", t.Host, err)
+	}
+	if err := uploadFile(client, binaryPath, filepath.Join(remoteDir, "quickpackage"), 0755); err != nil {
+		return fmt.Errorf("%s: failed to upload quickpackage binary: %w", t.Host, err)
+	}
+	if err := uploadFile(client, configPath, filepath.Join(remoteDir, "config.json"), 0644); err != nil {
+		return fmt.Errorf("%s: failed to upload config: %w", t.Host, err)
+	}
+
+	cmd := fmt.Sprintf("cd %s && ./quickpackage install --config config.json", remoteDir)
+	if err := runRemote(client, t, cmd); err != nil {
+		return fmt.Errorf("%s: %w", t.Host, err)
+	}
+	return nil
+}
+
+// RollbackOn runs `quickpackage rollback` against a host previously
+// deployed to with InstallOn.
+func RollbackOn(t Target, appName, to string) error {
+	client, err := t.dial()
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.Host, err)
+	}
+	defer client.Close()
+
+	cmd := fmt.Sprintf("cd %s && ./quickpackage rollback --config config.json", stagingDir(appName))
+	if to != "" {
+		cmd += " --to " + to
+	}
+	if err := runRemote(client, t, cmd); err != nil {
+		return fmt.Errorf("%s: %w", t.Host, err)
+	}
+	return nil
+}
+
+// stagingDir is relative to the SSH login user's default directory
+// (their home, for both the SFTP subsystem and an interactive shell),
+// so uploads never need root even when Become later sudos the actual
+// install into a privileged path like /opt.
+func stagingDir(appName string) string {
+	return "quickpackage-deploy-" + appName
+}
+
+// unameOS maps a Go GOOS value to what `uname -s` reports for it.
+var unameOS = map[string]string{
+	"linux":  "Linux",
+	"darwin": "Darwin",
+}
+
+// unameArch maps a Go GOARCH value to the `uname -m` strings hosts
+// report for it; arm64 alone differs between Linux ("aarch64") and
+// macOS ("arm64"), so both are accepted.
+var unameArch = map[string][]string{
+	"amd64": {"x86_64"},
+	"arm64": {"arm64", "aarch64"},
+	"386":   {"i386", "i686"},
+}
+
+// checkArch refuses to continue if the quickpackage binary running
+// locally (and about to be uploaded) was built for a different OS or
+// architecture than host reports, which would otherwise fail silently
+// once the remote `./quickpackage install` is invoked. Callers that
+// supply their own remote-matching binary via Target.BinaryPath skip
+// this check entirely.
+func checkArch(client *ssh.Client, host string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session for arch check: %w", err)
+	}
+	defer session.Close()
+
+	out, err := session.CombinedOutput("uname -s -m")
+	if err != nil {
+		return fmt.Errorf("failed to run uname on %s: %w", host, err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected uname output from %s: %q", host, strings.TrimSpace(string(out)))
+	}
+	remoteOS, remoteArch := fields[0], fields[1]
+
+	wantOS, ok := unameOS[runtime.GOOS]
+	if !ok || !strings.EqualFold(remoteOS, wantOS) {
+		return fmt.Errorf("quickpackage was built for %s but %s reports %s; set the target's binary_path (or --remote-binary) to a binary built for that host", runtime.GOOS, host, remoteOS)
+	}
+
+	wantArches := unameArch[runtime.GOARCH]
+	matched := len(wantArches) == 0
+	for _, want := range wantArches {
+		if strings.EqualFold(remoteArch, want) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return fmt.Errorf("quickpackage was built for %s but %s reports %s; set the target's binary_path (or --remote-binary) to a binary built for that host", runtime.GOARCH, host, remoteArch)
+	}
+	return nil
+}
+
+func (t Target) dial() (*ssh.Client, error) {
+	methods, err := t.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := defaultHostKeyCallback()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+
+	port := t.Port
+	if port == 0 {
+		port = 22
+	}
+
+	config := &ssh.ClientConfig{
+		User:            t.User,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}
+	return ssh.Dial("tcp", fmt.Sprintf("%s:%d", t.Host, port), config)
+}
+
+func (t Target) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if t.UseAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, fmt.Errorf("use_agent is set but SSH_AUTH_SOCK is not")
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if t.KeyPath != "" {
+		key, err := os.ReadFile(t.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", t.KeyPath, err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %s: %w", t.KeyPath, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no usable auth method (set key_path or use_agent)")
+	}
+	return methods, nil
+}
+
+func defaultHostKeyCallback() (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+}
+
+func uploadFile(client *ssh.Client, localPath, remotePath string, perm os.FileMode) error {
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to start sftp session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.MkdirAll(filepath.Dir(remotePath)); err != nil {
+		return fmt.Errorf("failed to create remote dir %s: %w", filepath.Dir(remotePath), err)
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+
+	f, err := sftpClient.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file %s: %w", remotePath, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write remote file %s: %w", remotePath, err)
+	}
+	return f.Chmod(perm)
+}
+
+func uploadDir(client *ssh.Client, localDir, remoteDir string) error {
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to start sftp session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.MkdirAll(remoteDir); err != nil {
+		return fmt.Errorf("failed to create remote dir %s: %w", remoteDir, err)
+	}
+
+	return filepath.Walk(localDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localDir, path)
+		if err != nil {
+			return err
+		}
+		remotePath := filepath.ToSlash(filepath.Join(remoteDir, rel))
+
+		if info.IsDir() {
+			return sftpClient.MkdirAll(remotePath)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := sftpClient.Create(remotePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		return f.Chmod(info.Mode())
+	})
+}
+
+func runRemote(client *ssh.Client, t Target, command string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	// command may be compound (`cd ... && ...`), so run it in a nested
+	// shell for the env prefix to reach every part of it.
+	if len(t.Environment) > 0 {
+		command = "sh -c " + shQuote(command)
+	}
+	command, err = withEnvironment(t.Environment, command)
+	if err != nil {
+		return err
+	}
+	if t.Become {
+		command = withBecome(t.BecomeUser, command)
+	}
+
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+	if err := session.Run(command); err != nil {
+		return fmt.Errorf("remote command %q failed: %w", command, err)
+	}
+	return nil
+}
+
+var envVarNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// withEnvironment prefixes command with `env KEY=VALUE ...`, so the
+// same shell invocation that runs command also sees t.Environment.
+// Values are single-quote escaped before interpolation since they can
+// come from a shared inventory file; variable names are restricted to
+// the POSIX identifier pattern rather than escaped, since a shell
+// can't assign to anything else anyway.
+func withEnvironment(env map[string]string, command string) (string, error) {
+	if len(env) == 0 {
+		return command, nil
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		if !envVarNameRe.MatchString(k) {
+			return "", fmt.Errorf("invalid environment variable name %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("env")
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, shQuote(env[k]))
+	}
+	b.WriteString(" ")
+	b.WriteString(command)
+	return b.String(), nil
+}
+
+// withBecome wraps command so the whole thing (not just its first
+// shell word) runs as user via sudo. command is passed to a nested
+// `sh -c` rather than interpolated directly, since it may itself
+// contain shell operators like `&&`.
+func withBecome(user, command string) string {
+	if user == "" {
+		user = "root"
+	}
+	return fmt.Sprintf("sudo -u %s -- sh -c %s", shQuote(user), shQuote(command))
+}
+
+// shQuote escapes s for safe interpolation into a POSIX shell command
+// line by single-quoting it, so environment values and become users
+// coming from a shared inventory file can't inject shell metacharacters.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
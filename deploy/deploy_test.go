@@ -0,0 +1,117 @@
+package deploy
+
+import "testing"
+
+func TestResolveBatchSize(t *testing.T) {
+	cases := []struct {
+		spec  string
+		total int
+		want  int
+	}{
+		{"25%", 10, 2},
+		{"50%", 3, 1},
+		{"100%", 7, 7},
+		{"5", 10, 5},
+		{"0", 10, 10},
+		{"bogus", 10, 10},
+		{"", 10, 10},
+	}
+	for _, c := range cases {
+		got := resolveBatchSize(c.spec, c.total)
+		if got != c.want {
+			t.Errorf("resolveBatchSize(%q, %d) = %d, want %d", c.spec, c.total, got, c.want)
+		}
+	}
+}
+
+func TestBatchTargetsNonRollingIsOneBatch(t *testing.T) {
+	targets := []Target{{Host: "a"}, {Host: "b"}, {Host: "c"}}
+	batches := batchTargets(targets, Strategy{})
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("batchTargets(non-rolling) = %v, want a single batch of 3", batches)
+	}
+}
+
+func TestBatchTargetsRollingSplitsByBatchSize(t *testing.T) {
+	targets := []Target{{Host: "a"}, {Host: "b"}, {Host: "c"}, {Host: "d"}, {Host: "e"}}
+	batches := batchTargets(targets, Strategy{Rolling: true, BatchSize: "2"})
+
+	if len(batches) != 3 {
+		t.Fatalf("got %d batches, want 3: %v", len(batches), batches)
+	}
+	if len(batches[0]) != 2 || len(batches[1]) != 2 || len(batches[2]) != 1 {
+		t.Fatalf("unexpected batch sizes: %v", batches)
+	}
+}
+
+func TestReportFailedAndOK(t *testing.T) {
+	ok := Report{Results: []HostResult{{Host: "a"}, {Host: "b"}}}
+	if !ok.OK() {
+		t.Error("Report with no errors should be OK")
+	}
+	if len(ok.Failed()) != 0 {
+		t.Errorf("Failed() = %v, want none", ok.Failed())
+	}
+
+	withFailure := Report{Results: []HostResult{{Host: "a"}, {Host: "b", Err: errTest}}}
+	if withFailure.OK() {
+		t.Error("Report with an error should not be OK")
+	}
+	if len(withFailure.Failed()) != 1 || withFailure.Failed()[0].Host != "b" {
+		t.Errorf("Failed() = %v, want just host b", withFailure.Failed())
+	}
+}
+
+var errTest = testErr("boom")
+
+type testErr string
+
+func (e testErr) Error() string { return string(e) }
+
+func TestShQuoteEscapesSingleQuotes(t *testing.T) {
+	cases := map[string]string{
+		"simple":      "'simple'",
+		"":            "''",
+		"it's":        `'it'\''s'`,
+		"a; rm -rf /": "'a; rm -rf /'",
+		"$(whoami)":   "'$(whoami)'",
+	}
+	for in, want := range cases {
+		if got := shQuote(in); got != want {
+			t.Errorf("shQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWithEnvironmentRejectsInvalidNames(t *testing.T) {
+	if _, err := withEnvironment(map[string]string{"FOO; rm -rf /": "bar"}, "true"); err == nil {
+		t.Fatal("withEnvironment should reject an environment variable name with shell metacharacters")
+	}
+}
+
+func TestWithEnvironmentQuotesValues(t *testing.T) {
+	got, err := withEnvironment(map[string]string{"FOO": "bar; rm -rf /"}, "true")
+	if err != nil {
+		t.Fatalf("withEnvironment: %v", err)
+	}
+	want := "env FOO='bar; rm -rf /' true"
+	if got != want {
+		t.Errorf("withEnvironment = %q, want %q", got, want)
+	}
+}
+
+func TestWithBecomeWrapsWholeCommandInNestedShell(t *testing.T) {
+	got := withBecome("deploy", "cd /opt/app && ./run.sh")
+	want := "sudo -u 'deploy' -- sh -c 'cd /opt/app && ./run.sh'"
+	if got != want {
+		t.Errorf("withBecome = %q, want %q", got, want)
+	}
+}
+
+func TestWithBecomeDefaultsToRoot(t *testing.T) {
+	got := withBecome("", "true")
+	want := "sudo -u 'root' -- sh -c 'true'"
+	if got != want {
+		t.Errorf("withBecome(\"\", ...) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+// Package executor abstracts the filesystem and process-execution
+// calls QuickPackage makes while building, installing, and uninstalling
+// an app, so those flows can run for real or simply be recorded for a
+// dry run without touching the system.
+package executor
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+
+	cp "github.com/otiai10/copy"
+)
+
+// Executor performs (or records) the mutating operations used by the
+// build/install/uninstall flows.
+type Executor interface {
+	Copy(src, dst string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	WriteFile(path string, data []byte, perm fs.FileMode) error
+	Remove(path string) error
+	RemoveAll(path string) error
+	Symlink(target, link string) error
+	Rename(oldPath, newPath string) error
+	RunScript(scriptPath, workDir string) error
+	RunCommand(name string, args ...string) error
+
+	// Exists reports whether path exists. Real checks the filesystem;
+	// Recording reports true for every path, since a dry run never
+	// actually creates anything it could check for.
+	Exists(path string) bool
+}
+
+// Real performs every operation against the live filesystem and OS.
+type Real struct{}
+
+func NewReal() *Real {
+	return &Real{}
+}
+
+func (r *Real) Copy(src, dst string) error                    { return cp.Copy(src, dst) }
+func (r *Real) MkdirAll(path string, perm fs.FileMode) error  { return os.MkdirAll(path, perm) }
+func (r *Real) Remove(path string) error                      { return os.Remove(path) }
+func (r *Real) RemoveAll(path string) error                   { return os.RemoveAll(path) }
+func (r *Real) Symlink(target, link string) error             { return os.Symlink(target, link) }
+func (r *Real) Rename(oldPath, newPath string) error           { return os.Rename(oldPath, newPath) }
+
+func (r *Real) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func (r *Real) RunScript(scriptPath, workDir string) error {
+	cmd := exec.Command("/bin/bash", scriptPath)
+	cmd.Dir = workDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (r *Real) RunCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (r *Real) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Recording performs no mutation at all; it logs each action it was
+// asked to take as a "[plan]" line and returns success, so dry runs and
+// `quickpackage plan` can walk the real code path safely.
+type Recording struct {
+	Actions []string
+}
+
+func NewRecording() *Recording {
+	return &Recording{}
+}
+
+func (r *Recording) record(format string, args ...any) {
+	line := fmt.Sprintf(format, args...)
+	r.Actions = append(r.Actions, line)
+	log.Printf("[plan] %s", line)
+}
+
+func (r *Recording) Copy(src, dst string) error {
+	r.record("copy %s -> %s", src, dst)
+	return nil
+}
+
+func (r *Recording) MkdirAll(path string, perm fs.FileMode) error {
+	r.record("mkdir -p %s", path)
+	return nil
+}
+
+func (r *Recording) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	r.record("write %s (%d bytes)", path, len(data))
+	return nil
+}
+
+func (r *Recording) Remove(path string) error {
+	r.record("remove %s", path)
+	return nil
+}
+
+func (r *Recording) RemoveAll(path string) error {
+	r.record("remove -r %s", path)
+	return nil
+}
+
+func (r *Recording) Symlink(target, link string) error {
+	r.record("symlink %s -> %s", link, target)
+	return nil
+}
+
+func (r *Recording) Rename(oldPath, newPath string) error {
+	r.record("rename %s -> %s", oldPath, newPath)
+	return nil
+}
+
+func (r *Recording) RunScript(scriptPath, workDir string) error {
+	r.record("run %s (in %s)", scriptPath, workDir)
+	return nil
+}
+
+func (r *Recording) RunCommand(name string, args ...string) error {
+	r.record("exec %s %v", name, args)
+	return nil
+}
+
+func (r *Recording) Exists(path string) bool {
+	return true
+}
@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingDoesNotTouchDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "somefile")
+
+	r := NewRecording()
+	if err := r.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := r.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := r.Copy("src", "dst"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if err := r.RunCommand("systemctl", "enable", "--now", "app"); err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Recording.WriteFile created %s on disk (stat err = %v)", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("Recording.MkdirAll created a directory on disk (stat err = %v)", err)
+	}
+	if !r.Exists(path) {
+		t.Error("Recording.Exists should always report true, since a dry run never creates anything")
+	}
+	if len(r.Actions) != 4 {
+		t.Fatalf("Actions recorded = %d, want 4: %v", len(r.Actions), r.Actions)
+	}
+}
+
+func TestRealWriteFileAndExists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "somefile")
+
+	r := NewReal()
+	if r.Exists(path) {
+		t.Fatal("Exists reported true for a file that was never created")
+	}
+	if err := r.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := r.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if !r.Exists(path) {
+		t.Fatal("Exists reported false for a file WriteFile just created")
+	}
+}
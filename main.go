@@ -1,38 +1,124 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	cp "github.com/otiai10/copy"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ElementalMP4/QuickPackage/deploy"
+	"github.com/ElementalMP4/QuickPackage/executor"
+	"github.com/ElementalMP4/QuickPackage/packager"
+	"github.com/ElementalMP4/QuickPackage/release"
+	"github.com/ElementalMP4/QuickPackage/service"
 )
 
 var InstallPath string = "/opt/"
 
 func main() {
 	if len(os.Args) < 2 {
-		log.Fatal("Usage: quickpackage [build|install|uninstall] [--config <path>]")
+		log.Fatal("Usage: quickpackage [build|install|uninstall|package|rollback|list-releases|prune|plan|deploy] [--config <path>]")
 	}
 
 	action := os.Args[1]
 	args := os.Args[2:]
 
 	configPath := ".qp/config.json"
+	packageFormat := ""
+	outputDir := "dist/"
+	rollbackTo := ""
+	keep := 5
+	dryRun := false
+	hosts := ""
+	inventory := ""
+	parallel := 1
+	strategy := ""
+	batch := ""
+	rollbackOnFailure := false
+	remoteBinary := ""
 	for i := 0; i < len(args); i++ {
-		if args[i] == "--config" && i+1 < len(args) {
-			configPath = args[i+1]
-			i++
+		switch args[i] {
+		case "--config":
+			if i+1 < len(args) {
+				configPath = args[i+1]
+				i++
+			}
+		case "--format":
+			if i+1 < len(args) {
+				packageFormat = args[i+1]
+				i++
+			}
+		case "--output":
+			if i+1 < len(args) {
+				outputDir = args[i+1]
+				i++
+			}
+		case "--to":
+			if i+1 < len(args) {
+				rollbackTo = args[i+1]
+				i++
+			}
+		case "--keep":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &keep)
+				i++
+			}
+		case "--dry-run":
+			dryRun = true
+		case "--hosts":
+			if i+1 < len(args) {
+				hosts = args[i+1]
+				i++
+			}
+		case "--inventory":
+			if i+1 < len(args) {
+				inventory = args[i+1]
+				i++
+			}
+		case "--parallel":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &parallel)
+				i++
+			}
+		case "--strategy":
+			if i+1 < len(args) {
+				strategy = args[i+1]
+				i++
+			}
+		case "--batch":
+			if i+1 < len(args) {
+				batch = args[i+1]
+				i++
+			}
+		case "--rollback-on-failure":
+			rollbackOnFailure = true
+		case "--remote-binary":
+			if i+1 < len(args) {
+				remoteBinary = args[i+1]
+				i++
+			}
 		}
 	}
 
-	if action != "build" && action != "install" && action != "uninstall" {
-		log.Fatalf("Unknown action %q. Must be one of: build, install, uninstall", action)
+	if action == "plan" {
+		dryRun = true
+	}
+
+	validActions := map[string]bool{
+		"build": true, "install": true, "uninstall": true, "package": true,
+		"rollback": true, "list-releases": true, "prune": true, "plan": true,
+		"deploy": true,
+	}
+	if !validActions[action] {
+		log.Fatalf("Unknown action %q. Must be one of: build, install, uninstall, package, rollback, list-releases, prune, plan, deploy", action)
 	}
 
 	cfg, err := loadConfig(configPath)
@@ -41,14 +127,37 @@ func main() {
 	}
 	validateConfig(cfg)
 
+	var ex executor.Executor = executor.NewReal()
+	if dryRun {
+		ex = executor.NewRecording()
+	}
+
 	switch action {
 	case "build":
-		doBuild(cfg)
+		doBuild(cfg, ex)
 	case "install":
-		doBuild(cfg)
-		doInstall(cfg)
+		doBuild(cfg, ex)
+		doInstall(cfg, configPath, ex, dryRun)
 	case "uninstall":
-		doUninstall(cfg)
+		doUninstall(cfg, ex)
+	case "package":
+		if packageFormat == "" {
+			log.Fatal("Usage: quickpackage package --format deb|rpm|tar [--output dist/]")
+		}
+		doBuild(cfg, ex)
+		doPackage(cfg, packageFormat, outputDir)
+	case "rollback":
+		doRollback(cfg, rollbackTo)
+	case "list-releases":
+		doListReleases(cfg)
+	case "prune":
+		doPrune(cfg, keep)
+	case "plan":
+		log.Println("Planning build + install (dry run, no changes will be made)")
+		doBuild(cfg, ex)
+		doInstall(cfg, configPath, ex, dryRun)
+	case "deploy":
+		doDeploy(cfg, configPath, hosts, inventory, parallel, strategy, batch, rollbackOnFailure, remoteBinary)
 	}
 }
 
@@ -76,7 +185,7 @@ func validateConfig(cfg *Config) {
 	}
 }
 
-func doBuild(cfg *Config) {
+func doBuild(cfg *Config, ex executor.Executor) {
 	buildDir, err := os.MkdirTemp("/tmp", "qp_build_"+cfg.AppName+"_")
 	if err != nil {
 		log.Fatalf("Failed to create build temp dir: %v", err)
@@ -94,7 +203,7 @@ func doBuild(cfg *Config) {
 		}
 
 		for _, src := range matches {
-			if err := copyPreserveRelBase(src, ".", buildDir); err != nil {
+			if err := copyPreserveRelBase(ex, src, ".", buildDir); err != nil {
 				log.Fatalf("%v", err)
 			}
 		}
@@ -102,18 +211,14 @@ func doBuild(cfg *Config) {
 
 	if cfg.BuildScript != "" {
 		scriptPath := filepath.Join(buildDir, filepath.Base(cfg.BuildScript))
-		if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-			if err := cp.Copy(cfg.GetBuildScript(), scriptPath); err != nil {
+		if !exists(scriptPath) {
+			if err := ex.Copy(cfg.GetBuildScript(), scriptPath); err != nil {
 				log.Fatalf("Failed to copy build script %s: %v", cfg.BuildScript, err)
 			}
 		}
 
 		log.Printf("Running build script: %s", scriptPath)
-		cmd := exec.Command("/bin/bash", scriptPath)
-		cmd.Dir = buildDir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+		if err := ex.RunScript(scriptPath, buildDir); err != nil {
 			log.Fatalf("Build script failed: %v", err)
 		}
 	} else {
@@ -123,7 +228,7 @@ func doBuild(cfg *Config) {
 	log.Printf("Build complete!")
 }
 
-func copyPreserveRelBase(src, baseDir, dstRoot string) error {
+func copyPreserveRelBase(ex executor.Executor, src, baseDir, dstRoot string) error {
 	relPath, err := filepath.Rel(baseDir, src)
 	if err != nil {
 		return fmt.Errorf("failed to compute relative path for %s: %w", src, err)
@@ -135,11 +240,11 @@ func copyPreserveRelBase(src, baseDir, dstRoot string) error {
 
 	dst := filepath.Join(dstRoot, relPath)
 
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+	if err := ex.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return fmt.Errorf("failed to create directories for %s: %w", dst, err)
 	}
 
-	if err := cp.Copy(src, dst); err != nil {
+	if err := ex.Copy(src, dst); err != nil {
 		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
 	}
 
@@ -147,37 +252,16 @@ func copyPreserveRelBase(src, baseDir, dstRoot string) error {
 	return nil
 }
 
-func doInstall(cfg *Config) {
+func doInstall(cfg *Config, configPath string, ex executor.Executor, dryRun bool) {
 	installDir := filepath.Join(InstallPath, cfg.AppName)
-	log.Printf("Installing to %s", installDir)
-
-	unit := UnitFromConfig(cfg)
+	version := releaseVersion(cfg)
+	log.Printf("Installing %s %s to %s", cfg.AppName, version, installDir)
 
-	if cfg.Systemd {
-		cmdCheck := exec.Command("systemctl", "is-active", "--quiet", unit.UnitNameWildcard())
-		if err := cmdCheck.Run(); err == nil {
-			log.Printf("Stopping active systemd service %s", unit.UnitNameWildcard())
-			cmdStop := exec.Command("systemctl", "stop", unit.UnitNameWildcard())
-			cmdStop.Stdout = os.Stdout
-			cmdStop.Stderr = os.Stderr
-			if err := cmdStop.Run(); err != nil {
-				log.Fatalf("Failed to stop systemd service %s: %v", unit.UnitNameWildcard(), err)
-			}
-			// Wait for the service to stop
-			for {
-				cmdStatus := exec.Command("systemctl", "is-active", "--quiet", unit.UnitNameWildcard())
-				if err := cmdStatus.Run(); err != nil {
-					break // service is stopped
-				}
-				log.Printf("Waiting for %s to stop...", unit.UnitNameWildcard())
-				time.Sleep(1 * time.Second)
-			}
-			log.Printf("Service %s stopped.", unit.UnitNameWildcard())
-		}
-	}
+	unit := UnitFromConfig(cfg, release.CurrentLink(installDir))
 
-	if err := os.MkdirAll(installDir, 0755); err != nil {
-		log.Fatalf("Failed to create install dir: %v", err)
+	releaseDir, err := release.Stage(ex, installDir, version)
+	if err != nil {
+		log.Fatalf("Failed to stage release %s: %v", version, err)
 	}
 
 	buildDir, _ := findTempBuildDir(cfg.AppName)
@@ -203,36 +287,57 @@ func doInstall(cfg *Config) {
 			log.Fatalf("Install source file %s does not exist", srcPath)
 		}
 
-		if err := copyPreserveRelBase(srcPath, baseDir, installDir); err != nil {
+		if err := copyPreserveRelBase(ex, srcPath, baseDir, releaseDir); err != nil {
 			log.Fatalf("%v", err)
 		}
 	}
 
 	if cfg.InstallScript != "" {
-		scriptPath := filepath.Join(installDir, filepath.Base(cfg.InstallScript))
+		scriptPath := filepath.Join(releaseDir, filepath.Base(cfg.InstallScript))
 		if !exists(scriptPath) {
-			if err := cp.Copy(cfg.GetInstallScript(), scriptPath); err != nil {
+			if err := ex.Copy(cfg.GetInstallScript(), scriptPath); err != nil {
 				log.Fatalf("Failed to copy install script %s: %v", cfg.InstallScript, err)
 			}
 		}
 
 		log.Printf("Running install script: %s", scriptPath)
-		runScript(scriptPath, installDir)
+		if err := ex.RunScript(scriptPath, releaseDir); err != nil {
+			log.Fatalf("Script %s failed: %v", scriptPath, err)
+		}
 	} else {
 		log.Println("No install script specified, skipping install script step")
 	}
 
+	sm := service.Detect(ex)
+	skipServiceActions := isUnguardedWindowsDryRun(sm, ex)
+
 	if cfg.Systemd {
-		if err := installSystemdUnit(cfg); err != nil {
-			log.Fatalf("Failed to install systemd unit: %v", err)
+		if dryRun {
+			planSystemdUnit(unit)
 		}
+		if skipServiceActions {
+			log.Printf("[plan] Windows service control manager does not support dry-run; skipping service actions for %s", unit.Name)
+		} else if err := sm.Status(unit.Name, unit.RunAsUser); err == nil {
+			log.Printf("Stopping active service %s", unit.Name)
+			if err := sm.Stop(unit.Name, unit.RunAsUser); err != nil {
+				log.Fatalf("Failed to stop service %s: %v", unit.Name, err)
+			}
+		}
+	}
 
-		log.Printf("Starting systemd service %s", unit.UnitNameWildcard())
-		cmdRestart := exec.Command("systemctl", "start", unit.UnitNameWildcard())
-		cmdRestart.Stdout = os.Stdout
-		cmdRestart.Stderr = os.Stderr
-		if err := cmdRestart.Run(); err != nil {
-			log.Fatalf("Failed to start systemd service %s: %v", unit.UnitNameWildcard(), err)
+	if err := release.Activate(ex, installDir, version, configHash(configPath)); err != nil {
+		log.Fatalf("Failed to activate release %s: %v", version, err)
+	}
+	log.Printf("Activated release %s", version)
+
+	if cfg.Systemd && !skipServiceActions {
+		if err := sm.Install(unit); err != nil {
+			log.Fatalf("Failed to install service: %v", err)
+		}
+
+		log.Printf("Starting service %s", unit.Name)
+		if err := sm.Start(unit.Name, unit.RunAsUser); err != nil {
+			log.Fatalf("Failed to start service %s: %v", unit.Name, err)
 		}
 	}
 
@@ -246,7 +351,9 @@ func doInstall(cfg *Config) {
 			path := filepath.Join(tmp, e.Name())
 			if strings.HasPrefix(e.Name(), prefix) {
 				log.Printf("Removing build directory after install: %s", path)
-				os.RemoveAll(path)
+				if err := ex.RemoveAll(path); err != nil {
+					log.Printf("Warning: failed to remove build directory %s: %v", path, err)
+				}
 			}
 		}
 	}
@@ -254,79 +361,433 @@ func doInstall(cfg *Config) {
 	log.Printf("Install completed")
 }
 
-func doUninstall(cfg *Config) {
+// releaseVersion picks the identifier a release is staged under: the
+// config's own version if it set one, otherwise a timestamp.
+func releaseVersion(cfg *Config) string {
+	if cfg.Version != "" {
+		return cfg.Version
+	}
+	return time.Now().Format("20060102150405")
+}
+
+func configHash(configPath string) string {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Printf("Warning: could not hash config %s: %v", configPath, err)
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func doRollback(cfg *Config, to string) {
 	installDir := filepath.Join(InstallPath, cfg.AppName)
+	sm := service.Detect(executor.NewReal())
+	unit := UnitFromConfig(cfg, release.CurrentLink(installDir))
 
 	if cfg.Systemd {
-		unit := UnitFromConfig(cfg)
-		log.Printf("Stopping and disabling systemd service %s", unit.UnitNameWildcard())
-		exec.Command("systemctl", "stop", unit.UnitNameWildcard()).Run()
-		exec.Command("systemctl", "disable", unit.UnitNameWildcard()).Run()
-		os.Remove(unit.UnitPath())
-		exec.Command("systemctl", "daemon-reload").Run()
+		if err := sm.Stop(unit.Name, unit.RunAsUser); err != nil {
+			log.Printf("Warning: failed to stop service %s before rollback: %v", unit.Name, err)
+		}
+	}
+
+	version, err := release.Rollback(executor.NewReal(), installDir, to)
+	if err != nil {
+		log.Fatalf("Rollback failed: %v", err)
+	}
+	log.Printf("Rolled back to release %s", version)
+
+	if cfg.Systemd {
+		if err := sm.Restart(unit.Name, unit.RunAsUser); err != nil {
+			log.Fatalf("Failed to restart service %s after rollback: %v", unit.Name, err)
+		}
+	}
+
+	log.Printf("Rollback completed")
+}
+
+func doListReleases(cfg *Config) {
+	installDir := filepath.Join(InstallPath, cfg.AppName)
+
+	releases, err := release.List(installDir)
+	if err != nil {
+		log.Fatalf("Failed to list releases: %v", err)
+	}
+
+	current, _ := release.CurrentVersion(installDir)
+	for _, r := range releases {
+		marker := " "
+		if r.Version == current {
+			marker = "*"
+		}
+		log.Printf("%s %s  installed=%s  healthy=%t", marker, r.Version, r.InstalledAt.Format(time.RFC3339), r.Healthy)
+	}
+}
+
+func doPrune(cfg *Config, keep int) {
+	installDir := filepath.Join(InstallPath, cfg.AppName)
+
+	removed, err := release.Prune(executor.NewReal(), installDir, keep)
+	if err != nil {
+		log.Fatalf("Failed to prune releases: %v", err)
+	}
+	if len(removed) == 0 {
+		log.Printf("Nothing to prune, %d or fewer releases present", keep)
+		return
+	}
+	log.Printf("Pruned releases: %s", strings.Join(removed, ", "))
+}
+
+// isUnguardedWindowsDryRun reports whether sm is the Windows SCM
+// backend being driven by a dry run. WindowsSCM takes no Executor and
+// every method mutates the real service manager unconditionally (see
+// its doc comment), so unlike the other backends it can't be trusted
+// to no-op under a Recording executor — callers must skip calling it
+// themselves instead.
+func isUnguardedWindowsDryRun(sm service.ServiceManager, ex executor.Executor) bool {
+	if _, ok := sm.(*service.WindowsSCM); !ok {
+		return false
+	}
+	_, recording := ex.(*executor.Recording)
+	return recording
+}
+
+// planSystemdUnit prints the unit file quickpackage would write for
+// unit, diffed line-by-line against whatever is already on disk, along
+// with its .socket/.timer companion units when unit asks for them.
+func planSystemdUnit(unit service.UnitSpec) {
+	planUnitFile(service.SystemdUnitPath(unit.Name, unit.RunAsUser), service.RenderSystemdUnit(unit))
+
+	if unit.Socket != nil {
+		planUnitFile(service.SystemdSocketPath(unit.Name), service.RenderSystemdSocket(unit))
+	}
+	if unit.Timer != nil {
+		planUnitFile(service.SystemdTimerPath(unit.Name), service.RenderSystemdTimer(unit))
+	}
+}
+
+// planUnitFile prints the text quickpackage would write to path,
+// diffed line-by-line against whatever is already there.
+func planUnitFile(path, newText string) {
+	oldText := ""
+	if data, err := os.ReadFile(path); err == nil {
+		oldText = string(data)
+	}
+
+	if oldText == newText {
+		log.Printf("[plan] %s is already up to date", path)
+		return
+	}
+
+	log.Printf("[plan] would write %s:", path)
+	printUnitDiff(oldText, newText)
+}
+
+// printUnitDiff prints a minimal line-by-line diff of two unit files.
+// Unit files are short and line-aligned by construction, so this does
+// not need to be a full longest-common-subsequence diff.
+func printUnitDiff(oldText, newText string) {
+	oldLines := strings.Split(strings.TrimRight(oldText, "\n"), "\n")
+	newLines := strings.Split(strings.TrimRight(newText, "\n"), "\n")
+
+	max := len(oldLines)
+	if len(newLines) > max {
+		max = len(newLines)
+	}
+
+	for i := 0; i < max; i++ {
+		var oldLine, newLine string
+		if i < len(oldLines) {
+			oldLine = oldLines[i]
+		}
+		if i < len(newLines) {
+			newLine = newLines[i]
+		}
+		switch {
+		case i >= len(oldLines):
+			log.Printf("  + %s", newLine)
+		case i >= len(newLines):
+			log.Printf("  - %s", oldLine)
+		case oldLine != newLine:
+			log.Printf("  - %s", oldLine)
+			log.Printf("  + %s", newLine)
+		}
+	}
+}
+
+func doPackage(cfg *Config, format, outputDir string) {
+	if cfg.Version == "" {
+		log.Fatal("config: version is required to build a package")
+	}
+	if cfg.Description == "" {
+		log.Fatal("config: description is required to build a package")
+	}
+
+	backend, err := packager.Get(format)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	buildDir, _ := findTempBuildDir(cfg.AppName)
+
+	spec := packager.Spec{
+		AppName:      cfg.AppName,
+		Version:      cfg.Version,
+		Maintainer:   cfg.Maintainer,
+		Description:  cfg.Description,
+		Depends:      cfg.Depends,
+		Conflicts:    cfg.Conflicts,
+		License:      cfg.License,
+		Architecture: cfg.Architecture,
+		Prefix:       filepath.Join(InstallPath, cfg.AppName),
+	}
+	if cfg.InstallScript != "" {
+		spec.InstallScript = cfg.GetInstallScript()
+	}
+	if cfg.UninstallScript != "" {
+		spec.UninstallScript = cfg.GetUninstallScript()
+	}
+
+	for _, entry := range cfg.InstallFiles {
+		var srcPath, baseDir string
+
+		switch entry.From {
+		case "cwd":
+			srcPath = entry.File
+			baseDir = "."
+		case "build":
+			if buildDir == "" {
+				log.Fatalf("Build directory unknown, but install file %q is marked from build", entry.File)
+			}
+			srcPath = filepath.Join(buildDir, entry.File)
+			baseDir = buildDir
+		default:
+			log.Fatalf("Unknown 'from' value %q for install file %q", entry.From, entry.File)
+		}
+
+		if !exists(srcPath) {
+			log.Fatalf("Install source file %s does not exist", srcPath)
+		}
+
+		relPath, err := filepath.Rel(baseDir, srcPath)
+		if err != nil {
+			log.Fatalf("Failed to compute relative path for %s: %v", srcPath, err)
+		}
+		if relPath == "." {
+			relPath = filepath.Base(srcPath)
+		}
+
+		spec.Files = append(spec.Files, packager.StagedFile{SrcPath: srcPath, DestRelPath: relPath})
+	}
+
+	if cfg.Systemd {
+		spec.SystemdUnit = service.RenderSystemdUnit(UnitFromConfig(cfg, spec.Prefix))
+	}
+
+	artifact, err := backend.Build(spec, outputDir)
+	if err != nil {
+		log.Fatalf("Failed to build %s package: %v", format, err)
+	}
+
+	log.Printf("Package built: %s", artifact)
+}
+
+func doUninstall(cfg *Config, ex executor.Executor) {
+	installDir := filepath.Join(InstallPath, cfg.AppName)
+
+	if cfg.Systemd {
+		unit := UnitFromConfig(cfg, release.CurrentLink(installDir))
+		sm := service.Detect(ex)
+		if isUnguardedWindowsDryRun(sm, ex) {
+			log.Printf("[plan] Windows service control manager does not support dry-run; skipping service actions for %s", unit.Name)
+		} else {
+			log.Printf("Stopping and disabling service %s", unit.Name)
+			if err := sm.Uninstall(unit.Name, unit.RunAsUser); err != nil {
+				log.Printf("Warning: failed to uninstall service %s: %v", unit.Name, err)
+			}
+		}
 	}
 
 	if cfg.UninstallScript != "" {
 		scriptPath := filepath.Join(installDir, filepath.Base(cfg.UninstallScript))
 		if !exists(scriptPath) {
-			err := copyFileOrDir(cfg.GetUninstallScript(), scriptPath)
-			if err != nil {
+			if err := ex.Copy(cfg.GetUninstallScript(), scriptPath); err != nil {
 				log.Fatalf("Failed to copy uninstall script %s: %v", cfg.UninstallScript, err)
 			}
 		}
 		log.Printf("Running uninstall script: %s", scriptPath)
-		runScript(scriptPath, installDir)
+		if err := ex.RunScript(scriptPath, installDir); err != nil {
+			log.Fatalf("Script %s failed: %v", scriptPath, err)
+		}
 	} else {
 		log.Println("No uninstall script specified, skipping uninstall script step")
 	}
 
 	log.Printf("Removing install directory %s", installDir)
-	os.RemoveAll(installDir)
+	if err := ex.RemoveAll(installDir); err != nil {
+		log.Printf("Warning: failed to remove install directory %s: %v", installDir, err)
+	}
 
 	log.Printf("Uninstall completed")
 }
 
-func copyFileOrDir(src, dst string) error {
-	return cp.Copy(src, dst)
+// doDeploy builds locally, then pushes the build and config out to a
+// fleet of remote hosts over SSH and runs the install there, reporting
+// a summary and optionally rolling back hosts that succeeded if any
+// host in the run failed.
+func doDeploy(cfg *Config, configPath, hosts, inventory string, parallel int, strategy, batch string, rollbackOnFailure bool, remoteBinary string) {
+	targets := resolveTargets(cfg, hosts, inventory)
+	if len(targets) == 0 {
+		log.Fatal("No deploy targets: pass --hosts, --inventory, or set \"targets\" in the config")
+	}
+
+	doBuild(cfg, executor.NewReal())
+	buildDir, err := findTempBuildDir(cfg.AppName)
+	if err != nil {
+		log.Fatalf("Failed to locate build directory: %v", err)
+	}
+
+	localBinaryPath, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to resolve quickpackage binary path: %v", err)
+	}
+
+	st := deploy.Strategy{Rolling: strategy == "rolling", BatchSize: batch}
+
+	log.Printf("Deploying %s to %d host(s)", cfg.AppName, len(targets))
+	report := deploy.Deploy(targets, parallel, st, func(t deploy.Target) error {
+		binaryPath := localBinaryPath
+		if t.BinaryPath == "" && remoteBinary != "" {
+			t.BinaryPath = remoteBinary
+		}
+		if t.BinaryPath != "" {
+			binaryPath = t.BinaryPath
+		}
+		log.Printf("Installing on %s", t.Host)
+		return deploy.InstallOn(t, binaryPath, buildDir, configPath, cfg.AppName)
+	})
+
+	for _, r := range report.Results {
+		if r.Err != nil {
+			log.Printf("FAILED %s: %v", r.Host, r.Err)
+		} else {
+			log.Printf("OK %s", r.Host)
+		}
+	}
+
+	if report.OK() {
+		log.Printf("Deploy completed on all %d host(s)", len(report.Results))
+		return
+	}
+
+	log.Printf("Deploy failed on %d/%d host(s)", len(report.Failed()), len(report.Results))
+	if rollbackOnFailure {
+		log.Printf("Rolling back succeeded hosts")
+		for _, r := range report.Results {
+			if r.Err != nil {
+				continue
+			}
+			target := targetFor(targets, r.Host)
+			if err := deploy.RollbackOn(target, cfg.AppName, ""); err != nil {
+				log.Printf("Rollback failed on %s: %v", r.Host, err)
+			}
+		}
+	}
+	os.Exit(1)
 }
 
-func exists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
+// resolveTargets merges the config's own "targets" section with an
+// optional --inventory file and filters (or extends) the result by
+// --hosts, a comma-separated list of "host" or "user@host" entries.
+func resolveTargets(cfg *Config, hostsFlag, inventoryPath string) []deploy.Target {
+	byHost := map[string]TargetConfig{}
+	for _, t := range cfg.Targets {
+		byHost[t.Host] = t
+	}
+
+	if inventoryPath != "" {
+		inv, err := loadInventory(inventoryPath)
+		if err != nil {
+			log.Fatalf("Failed to load inventory %s: %v", inventoryPath, err)
+		}
+		for _, t := range inv {
+			byHost[t.Host] = t
+		}
+	}
+
+	var selected []TargetConfig
+	if hostsFlag != "" {
+		for _, h := range strings.Split(hostsFlag, ",") {
+			h = strings.TrimSpace(h)
+			if h == "" {
+				continue
+			}
+			user, host := splitUserHost(h)
+			tc, ok := byHost[host]
+			if !ok {
+				tc = TargetConfig{Host: host}
+			}
+			if user != "" {
+				tc.User = user
+			}
+			selected = append(selected, tc)
+		}
+	} else {
+		for _, tc := range byHost {
+			selected = append(selected, tc)
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i].Host < selected[j].Host })
+	}
+
+	targets := make([]deploy.Target, 0, len(selected))
+	for _, tc := range selected {
+		targets = append(targets, deploy.Target{
+			Host:        tc.Host,
+			User:        tc.User,
+			KeyPath:     tc.KeyPath,
+			UseAgent:    tc.UseAgent,
+			Become:      tc.Become,
+			BecomeUser:  tc.BecomeUser,
+			Environment: tc.Environment,
+			BinaryPath:  tc.BinaryPath,
+		})
+	}
+	return targets
 }
 
-func runScript(scriptPath, workDir string) {
-	cmd := exec.Command("/bin/bash", scriptPath)
-	cmd.Dir = workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		log.Fatalf("Script %s failed: %v", scriptPath, err)
+func splitUserHost(s string) (user, host string) {
+	if i := strings.Index(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
 	}
+	return "", s
 }
 
-func installSystemdUnit(cfg *Config) error {
-	unit := UnitFromConfig(cfg)
-	err := os.WriteFile(unit.UnitPath(), []byte(unit.GenerateFile()), 0644)
+func loadInventory(path string) ([]TargetConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	log.Printf("Wrote systemd unit to %s", unit.UnitPath())
-
-	cmds := [][]string{
-		{"systemctl", "daemon-reload"},
-		{"systemctl", "enable", "--now", unit.UnitNameWildcard()},
+	var inv struct {
+		Hosts []TargetConfig `yaml:"hosts"`
 	}
-	for _, args := range cmds {
-		cmd := exec.Command(args[0], args[1:]...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("%s failed: %w", strings.Join(args, " "), err)
+	if err := yaml.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
+	}
+	return inv.Hosts, nil
+}
+
+func targetFor(targets []deploy.Target, host string) deploy.Target {
+	for _, t := range targets {
+		if t.Host == host {
+			return t
 		}
 	}
-	return nil
+	return deploy.Target{Host: host}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func findTempBuildDir(appName string) (string, error) {
@@ -0,0 +1,151 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	cp "github.com/otiai10/copy"
+)
+
+const debSystemdUnitDir = "usr/lib/systemd/system/"
+
+// Deb stages a Debian package tree and invokes dpkg-deb --build.
+type Deb struct{}
+
+func (d *Deb) Build(spec Spec, outputDir string) (string, error) {
+	stageDir, err := os.MkdirTemp("/tmp", "qp_deb_"+spec.AppName+"_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create deb staging dir: %w", err)
+	}
+	defer os.RemoveAll(stageDir)
+
+	debianDir := filepath.Join(stageDir, "DEBIAN")
+	if err := os.MkdirAll(debianDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create DEBIAN dir: %w", err)
+	}
+
+	if err := stageFiles(spec, stageDir); err != nil {
+		return "", err
+	}
+
+	if spec.SystemdUnit != "" {
+		if err := writeFile(filepath.Join(stageDir, debSystemdUnitDir, spec.AppName+".service"), spec.SystemdUnit); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(debianDir, "control"), []byte(d.control(spec)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write control file: %w", err)
+	}
+
+	if err := d.writeMaintainerScript(debianDir, "postinst", spec.InstallScript, spec); err != nil {
+		return "", err
+	}
+	if err := d.writeMaintainerScript(debianDir, "prerm", spec.UninstallScript, spec); err != nil {
+		return "", err
+	}
+	if err := d.writeMaintainerScript(debianDir, "postrm", "", spec); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	artifact := filepath.Join(outputDir, fmt.Sprintf("%s_%s_%s.deb", spec.AppName, spec.Version, d.arch(spec)))
+	cmd := exec.Command("dpkg-deb", "--build", "--root-owner-group", stageDir, artifact)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("dpkg-deb --build failed: %w", err)
+	}
+
+	return artifact, nil
+}
+
+func (d *Deb) arch(spec Spec) string {
+	if spec.Architecture != "" {
+		return spec.Architecture
+	}
+	return "amd64"
+}
+
+func (d *Deb) control(spec Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package: %s\n", spec.AppName)
+	fmt.Fprintf(&b, "Version: %s\n", spec.Version)
+	fmt.Fprintf(&b, "Architecture: %s\n", d.arch(spec))
+	fmt.Fprintf(&b, "Maintainer: %s\n", spec.Maintainer)
+	if len(spec.Depends) > 0 {
+		fmt.Fprintf(&b, "Depends: %s\n", strings.Join(spec.Depends, ", "))
+	}
+	if len(spec.Conflicts) > 0 {
+		fmt.Fprintf(&b, "Conflicts: %s\n", strings.Join(spec.Conflicts, ", "))
+	}
+	fmt.Fprintf(&b, "Description: %s\n", spec.Description)
+	return b.String()
+}
+
+func (d *Deb) writeMaintainerScript(debianDir, name, innerScript string, spec Spec) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\nset -e\n\n")
+
+	switch name {
+	case "postinst":
+		if spec.SystemdUnit != "" {
+			b.WriteString("systemctl daemon-reload\nsystemctl enable --now " + spec.AppName + "\n")
+		}
+	case "prerm":
+		if spec.SystemdUnit != "" {
+			b.WriteString("systemctl stop " + spec.AppName + " || true\n")
+		}
+	case "postrm":
+		if spec.SystemdUnit != "" {
+			b.WriteString("systemctl disable " + spec.AppName + " || true\nsystemctl daemon-reload\n")
+		}
+	}
+
+	if innerScript != "" {
+		contents, err := os.ReadFile(innerScript)
+		if err != nil {
+			return fmt.Errorf("failed to read %s script %s: %w", name, innerScript, err)
+		}
+		fmt.Fprintf(&b, "cd %s\n", installPrefix(spec))
+		b.Write(contents)
+		b.WriteString("\n")
+	}
+
+	return os.WriteFile(filepath.Join(debianDir, name), []byte(b.String()), 0755)
+}
+
+// installPrefix returns spec.Prefix as an absolute path, so maintainer
+// scripts can cd into it before running install_script/uninstall_script
+// instead of inheriting whatever directory dpkg/rpm happened to invoke
+// them from.
+func installPrefix(spec Spec) string {
+	return "/" + strings.TrimPrefix(spec.Prefix, "/")
+}
+
+func stageFiles(spec Spec, stageDir string) error {
+	prefix := strings.TrimPrefix(spec.Prefix, "/")
+	for _, f := range spec.Files {
+		dst := filepath.Join(stageDir, prefix, f.DestRelPath)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+		}
+		if err := cp.Copy(f.SrcPath, dst); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", f.SrcPath, err)
+		}
+	}
+	return nil
+}
+
+func writeFile(path, contents string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return os.WriteFile(path, []byte(contents), 0644)
+}
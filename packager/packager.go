@@ -0,0 +1,59 @@
+package packager
+
+import "fmt"
+
+// StagedFile is a file that should be placed at DestRelPath (relative
+// to the package's install prefix) when the package is built.
+type StagedFile struct {
+	SrcPath     string
+	DestRelPath string
+}
+
+// Spec describes everything a Backend needs to produce a distributable
+// artifact for an app, independent of the target package format.
+type Spec struct {
+	AppName      string
+	Version      string
+	Maintainer   string
+	Description  string
+	Depends      []string
+	Conflicts    []string
+	License      string
+	Architecture string
+
+	// Prefix is the install path the package unpacks to, e.g. /opt/<app_name>.
+	Prefix string
+
+	Files []StagedFile
+
+	// InstallScript/UninstallScript are paths to the scripts shipped
+	// by the config; backends wire these into their maintainer
+	// script hooks (e.g. postinst/prerm for deb, %post/%preun for rpm).
+	InstallScript   string
+	UninstallScript string
+
+	// SystemdUnit is the rendered unit file text, staged into the
+	// package and activated from the maintainer scripts rather than
+	// written straight to the host.
+	SystemdUnit string
+}
+
+// Backend builds one package format from a Spec, writing the resulting
+// artifact into outputDir and returning its path.
+type Backend interface {
+	Build(spec Spec, outputDir string) (string, error)
+}
+
+// Get resolves a package format name ("deb", "rpm", "tar") to its Backend.
+func Get(format string) (Backend, error) {
+	switch format {
+	case "deb":
+		return &Deb{}, nil
+	case "rpm":
+		return &RPM{}, nil
+	case "tar":
+		return &Tar{}, nil
+	default:
+		return nil, fmt.Errorf("unknown package format %q, must be one of: deb, rpm, tar", format)
+	}
+}
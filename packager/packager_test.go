@@ -0,0 +1,116 @@
+package packager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	for _, format := range []string{"deb", "rpm", "tar"} {
+		if _, err := Get(format); err != nil {
+			t.Errorf("Get(%q) returned error: %v", format, err)
+		}
+	}
+
+	if _, err := Get("msi"); err == nil {
+		t.Error("Get(\"msi\") should have returned an error for an unknown format")
+	}
+}
+
+func TestRPMSpecOmitsScriptSectionsWhenNoScripts(t *testing.T) {
+	spec := Spec{
+		AppName: "demo",
+		Version: "1.0.0",
+	}
+
+	rendered, err := (&RPM{}).spec(spec)
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+
+	if strings.Contains(rendered, "%post") || strings.Contains(rendered, "%preun") {
+		t.Errorf("spec rendered a %%post/%%preun section with no install/uninstall script:\n%s", rendered)
+	}
+}
+
+func TestRPMSpecIncludesScriptContent(t *testing.T) {
+	dir := t.TempDir()
+	installScript := filepath.Join(dir, "install.sh")
+	uninstallScript := filepath.Join(dir, "uninstall.sh")
+	if err := os.WriteFile(installScript, []byte("echo installing\n"), 0755); err != nil {
+		t.Fatalf("WriteFile(install): %v", err)
+	}
+	if err := os.WriteFile(uninstallScript, []byte("echo uninstalling\n"), 0755); err != nil {
+		t.Fatalf("WriteFile(uninstall): %v", err)
+	}
+
+	spec := Spec{
+		AppName:         "demo",
+		Version:         "1.0.0",
+		Prefix:          "/opt/demo",
+		InstallScript:   installScript,
+		UninstallScript: uninstallScript,
+		SystemdUnit:     "[Unit]\n",
+	}
+
+	rendered, err := (&RPM{}).spec(spec)
+	if err != nil {
+		t.Fatalf("spec: %v", err)
+	}
+
+	if !strings.Contains(rendered, "%post") || !strings.Contains(rendered, "echo installing") {
+		t.Errorf("spec did not embed install script content in %%post:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "%preun") || !strings.Contains(rendered, "echo uninstalling") {
+		t.Errorf("spec did not embed uninstall script content in %%preun:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "cd /opt/demo\necho installing") {
+		t.Errorf("%%post should cd into the install prefix before running install_script:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "cd /opt/demo\necho uninstalling") {
+		t.Errorf("%%preun should cd into the install prefix before running uninstall_script:\n%s", rendered)
+	}
+}
+
+func TestDebMaintainerScriptsCdIntoPrefix(t *testing.T) {
+	dir := t.TempDir()
+	installScript := filepath.Join(dir, "install.sh")
+	if err := os.WriteFile(installScript, []byte("echo installing\n"), 0755); err != nil {
+		t.Fatalf("WriteFile(install): %v", err)
+	}
+
+	spec := Spec{
+		AppName:       "demo",
+		Version:       "1.0.0",
+		Prefix:        "/opt/demo",
+		InstallScript: installScript,
+	}
+
+	var b strings.Builder
+	if err := (&Deb{}).writeMaintainerScript(dir, "postinst", spec.InstallScript, spec); err != nil {
+		t.Fatalf("writeMaintainerScript: %v", err)
+	}
+	contents, err := os.ReadFile(filepath.Join(dir, "postinst"))
+	if err != nil {
+		t.Fatalf("ReadFile(postinst): %v", err)
+	}
+	b.Write(contents)
+
+	if !strings.Contains(b.String(), "cd /opt/demo\necho installing") {
+		t.Errorf("postinst should cd into the install prefix before running install_script:\n%s", b.String())
+	}
+}
+
+func TestRPMSpecFailsOnUnreadableScript(t *testing.T) {
+	spec := Spec{
+		AppName:       "demo",
+		Version:       "1.0.0",
+		InstallScript: filepath.Join(t.TempDir(), "does-not-exist.sh"),
+	}
+
+	if _, err := (&RPM{}).spec(spec); err == nil {
+		t.Fatal("spec should fail the build when install_script can't be read, not embed a comment and continue")
+	}
+}
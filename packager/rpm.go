@@ -0,0 +1,170 @@
+package packager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const rpmSystemdUnitDir = "usr/lib/systemd/system/"
+
+// RPM generates a .spec file describing the package and its files, then
+// invokes rpmbuild -bb against it.
+type RPM struct{}
+
+func (r *RPM) Build(spec Spec, outputDir string) (string, error) {
+	buildRoot, err := os.MkdirTemp("/tmp", "qp_rpm_"+spec.AppName+"_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create rpmbuild root: %w", err)
+	}
+	defer os.RemoveAll(buildRoot)
+
+	sourceRoot := filepath.Join(buildRoot, "BUILDROOT")
+	if err := stageFiles(spec, sourceRoot); err != nil {
+		return "", err
+	}
+	if spec.SystemdUnit != "" {
+		if err := writeFile(filepath.Join(sourceRoot, rpmSystemdUnitDir, spec.AppName+".service"), spec.SystemdUnit); err != nil {
+			return "", err
+		}
+	}
+
+	specsDir := filepath.Join(buildRoot, "SPECS")
+	if err := os.MkdirAll(specsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create SPECS dir: %w", err)
+	}
+	rendered, err := r.spec(spec)
+	if err != nil {
+		return "", err
+	}
+	specPath := filepath.Join(specsDir, spec.AppName+".spec")
+	if err := os.WriteFile(specPath, []byte(rendered), 0644); err != nil {
+		return "", fmt.Errorf("failed to write spec file: %w", err)
+	}
+
+	for _, dir := range []string{"RPMS", "SRPMS", "BUILD"} {
+		if err := os.MkdirAll(filepath.Join(buildRoot, dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s dir: %w", dir, err)
+		}
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	cmd := exec.Command("rpmbuild",
+		"--define", "_topdir "+buildRoot,
+		"--buildroot", sourceRoot,
+		"-bb", specPath,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("rpmbuild -bb failed: %w", err)
+	}
+
+	artifact, err := r.findBuiltRPM(filepath.Join(buildRoot, "RPMS"))
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(outputDir, filepath.Base(artifact))
+	if err := os.Rename(artifact, dest); err != nil {
+		return "", fmt.Errorf("failed to move built rpm to %s: %w", dest, err)
+	}
+	return dest, nil
+}
+
+func (r *RPM) arch(spec Spec) string {
+	if spec.Architecture != "" {
+		return spec.Architecture
+	}
+	return "x86_64"
+}
+
+func (r *RPM) spec(spec Spec) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Name: %s\n", spec.AppName)
+	fmt.Fprintf(&b, "Version: %s\n", spec.Version)
+	fmt.Fprintf(&b, "Release: 1\n")
+	fmt.Fprintf(&b, "Summary: %s\n", spec.Description)
+	fmt.Fprintf(&b, "License: %s\n", spec.License)
+	fmt.Fprintf(&b, "BuildArch: %s\n", r.arch(spec))
+	for _, d := range spec.Depends {
+		fmt.Fprintf(&b, "Requires: %s\n", d)
+	}
+	for _, c := range spec.Conflicts {
+		fmt.Fprintf(&b, "Conflicts: %s\n", c)
+	}
+
+	b.WriteString("\n%description\n")
+	b.WriteString(spec.Description + "\n")
+
+	b.WriteString("\n%files\n")
+	prefix := installPrefix(spec)
+	for _, f := range spec.Files {
+		fmt.Fprintf(&b, "%s\n", filepath.Join(prefix, f.DestRelPath))
+	}
+	if spec.SystemdUnit != "" {
+		fmt.Fprintf(&b, "/%s%s.service\n", rpmSystemdUnitDir, spec.AppName)
+	}
+
+	if spec.InstallScript != "" || spec.SystemdUnit != "" {
+		b.WriteString("\n%post\n")
+		if spec.InstallScript != "" {
+			fmt.Fprintf(&b, "cd %s\n", prefix)
+			if err := writeScriptInto(&b, spec.InstallScript); err != nil {
+				return "", fmt.Errorf("%%post: %w", err)
+			}
+		}
+		if spec.SystemdUnit != "" {
+			b.WriteString("systemctl daemon-reload\nsystemctl enable --now " + spec.AppName + "\n")
+		}
+	}
+	if spec.UninstallScript != "" || spec.SystemdUnit != "" {
+		b.WriteString("\n%preun\n")
+		if spec.SystemdUnit != "" {
+			b.WriteString("systemctl stop " + spec.AppName + " || true\nsystemctl disable " + spec.AppName + " || true\n")
+		}
+		if spec.UninstallScript != "" {
+			fmt.Fprintf(&b, "cd %s\n", prefix)
+			if err := writeScriptInto(&b, spec.UninstallScript); err != nil {
+				return "", fmt.Errorf("%%preun: %w", err)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+func (r *RPM) findBuiltRPM(rpmsDir string) (string, error) {
+	var found string
+	err := filepath.WalkDir(rpmsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".rpm") {
+			found = path
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan RPMS dir: %w", err)
+	}
+	if found == "" {
+		return "", fmt.Errorf("rpmbuild did not produce an rpm under %s", rpmsDir)
+	}
+	return found, nil
+}
+
+func writeScriptInto(b *strings.Builder, scriptPath string) error {
+	contents, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", scriptPath, err)
+	}
+	b.Write(contents)
+	b.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tarSystemdUnitDir = "usr/lib/systemd/system/"
+
+// Tar produces a gzipped tarball of the staged files plus a manifest
+// describing the package, for environments without deb or rpm tooling.
+type Tar struct{}
+
+func (t *Tar) Build(spec Spec, outputDir string) (string, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	artifact := filepath.Join(outputDir, fmt.Sprintf("%s_%s.tar.gz", spec.AppName, spec.Version))
+	f, err := os.Create(artifact)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", artifact, err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	prefix := strings.TrimPrefix(spec.Prefix, "/")
+	for _, file := range spec.Files {
+		if err := addFileToTar(tw, file.SrcPath, filepath.Join(prefix, file.DestRelPath)); err != nil {
+			return "", err
+		}
+	}
+
+	if spec.SystemdUnit != "" {
+		if err := addBytesToTar(tw, filepath.Join(tarSystemdUnitDir, spec.AppName+".service"), []byte(spec.SystemdUnit)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := addBytesToTar(tw, "MANIFEST", []byte(t.manifest(spec))); err != nil {
+		return "", err
+	}
+
+	return artifact, nil
+}
+
+func (t *Tar) manifest(spec Spec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %s\n", spec.AppName)
+	fmt.Fprintf(&b, "version: %s\n", spec.Version)
+	fmt.Fprintf(&b, "maintainer: %s\n", spec.Maintainer)
+	fmt.Fprintf(&b, "description: %s\n", spec.Description)
+	fmt.Fprintf(&b, "license: %s\n", spec.License)
+	fmt.Fprintf(&b, "architecture: %s\n", spec.Architecture)
+	if len(spec.Depends) > 0 {
+		fmt.Fprintf(&b, "depends: %s\n", strings.Join(spec.Depends, ", "))
+	}
+	if len(spec.Conflicts) > 0 {
+		fmt.Fprintf(&b, "conflicts: %s\n", strings.Join(spec.Conflicts, ", "))
+	}
+	return b.String()
+}
+
+func addFileToTar(tw *tar.Writer, srcPath, destRelPath string) error {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", srcPath, err)
+	}
+	if !srcInfo.IsDir() {
+		return addRegularFileToTar(tw, srcPath, destRelPath, srcInfo)
+	}
+
+	return filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.ToSlash(filepath.Join(destRelPath, rel))
+
+		if info.IsDir() {
+			hdr := &tar.Header{
+				Name:     destPath + "/",
+				Mode:     int64(info.Mode().Perm()),
+				Typeflag: tar.TypeDir,
+			}
+			return tw.WriteHeader(hdr)
+		}
+		return addRegularFileToTar(tw, path, destPath, info)
+	})
+}
+
+func addRegularFileToTar(tw *tar.Writer, srcPath, destRelPath string, info os.FileInfo) error {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", srcPath, err)
+	}
+
+	hdr := &tar.Header{
+		Name: destRelPath,
+		Mode: int64(info.Mode().Perm()),
+		Size: int64(len(data)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", destRelPath, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s into tarball: %w", destRelPath, err)
+	}
+	return nil
+}
+
+func addBytesToTar(tw *tar.Writer, destRelPath string, data []byte) error {
+	hdr := &tar.Header{
+		Name: destRelPath,
+		Mode: 0644,
+		Size: int64(len(data)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", destRelPath, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s into tarball: %w", destRelPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package release manages versioned install layouts of the form
+// <installDir>/releases/<version>/ with a `current` symlink pointing
+// at the active release, so installs can be staged, activated
+// atomically, and rolled back.
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+// Record describes one release that has been staged under installDir.
+type Record struct {
+	Version     string    `json:"version"`
+	InstalledAt time.Time `json:"installed_at"`
+	ConfigHash  string    `json:"config_hash"`
+	Healthy     bool      `json:"healthy"`
+
+	// Seq is a monotonic install sequence number, assigned once the
+	// first time a version is activated and never reassigned on a
+	// later reactivation (e.g. rolling back to it). It records real
+	// chronological install order independent of where Activate
+	// happens to leave the record in Releases.
+	Seq int `json:"seq"`
+}
+
+type manifest struct {
+	Releases []Record `json:"releases"`
+	NextSeq  int      `json:"next_seq"`
+}
+
+const manifestFile = "releases.json"
+
+func ReleasesDir(installDir string) string {
+	return filepath.Join(installDir, "releases")
+}
+
+func CurrentLink(installDir string) string {
+	return filepath.Join(installDir, "current")
+}
+
+func manifestPath(installDir string) string {
+	return filepath.Join(installDir, manifestFile)
+}
+
+// Stage creates a fresh, empty directory for version under installDir's
+// releases tree, ready for the caller to populate before Activate.
+func Stage(ex executor.Executor, installDir, version string) (string, error) {
+	dir := filepath.Join(ReleasesDir(installDir), version)
+	if err := ex.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create release dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// Activate atomically flips the `current` symlink to version and
+// records it in releases.json as the newest, healthy release.
+func Activate(ex executor.Executor, installDir, version, configHash string) error {
+	releaseDir := filepath.Join(ReleasesDir(installDir), version)
+	if !ex.Exists(releaseDir) {
+		return fmt.Errorf("release %s has not been staged under %s", version, installDir)
+	}
+
+	link := CurrentLink(installDir)
+	tmpLink := link + ".tmp"
+	_ = ex.Remove(tmpLink)
+	if err := ex.Symlink(releaseDir, tmpLink); err != nil {
+		return fmt.Errorf("failed to create symlink for release %s: %w", version, err)
+	}
+	if err := ex.Rename(tmpLink, link); err != nil {
+		return fmt.Errorf("failed to activate release %s: %w", version, err)
+	}
+
+	m, err := loadManifest(installDir)
+	if err != nil {
+		return err
+	}
+
+	seq := seqFor(m.Releases, version)
+	if seq == 0 {
+		m.NextSeq++
+		seq = m.NextSeq
+	}
+
+	m.Releases = append(removeRecord(m.Releases, version), Record{
+		Version:     version,
+		InstalledAt: time.Now(),
+		ConfigHash:  configHash,
+		Healthy:     true,
+		Seq:         seq,
+	})
+	return saveManifest(ex, installDir, m)
+}
+
+// CurrentVersion returns the version the `current` symlink points at.
+func CurrentVersion(installDir string) (string, error) {
+	target, err := os.Readlink(CurrentLink(installDir))
+	if err != nil {
+		return "", fmt.Errorf("failed to read current release symlink: %w", err)
+	}
+	return filepath.Base(target), nil
+}
+
+// Rollback points `current` at the previous release (or the explicit
+// "to" version, if given) and returns the version it rolled back to.
+func Rollback(ex executor.Executor, installDir, to string) (string, error) {
+	m, err := loadManifest(installDir)
+	if err != nil {
+		return "", err
+	}
+	if len(m.Releases) == 0 {
+		return "", fmt.Errorf("no releases recorded under %s", installDir)
+	}
+
+	target := to
+	if target == "" {
+		current, err := CurrentVersion(installDir)
+		if err != nil {
+			return "", err
+		}
+		target, err = previousVersion(m.Releases, current)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := Activate(ex, installDir, target, recordFor(m.Releases, target).ConfigHash); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// List returns every recorded release, oldest first by real install
+// order (Record.Seq), not by position in the manifest.
+func List(installDir string) ([]Record, error) {
+	m, err := loadManifest(installDir)
+	if err != nil {
+		return nil, err
+	}
+	return sortedBySeq(m.Releases), nil
+}
+
+// Prune removes all but the newest keep releases (and never the
+// currently active one), returning the versions it removed.
+func Prune(ex executor.Executor, installDir string, keep int) ([]string, error) {
+	m, err := loadManifest(installDir)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := CurrentVersion(installDir)
+
+	var removable []Record
+	for _, r := range sortedBySeq(m.Releases) {
+		if r.Version != current {
+			removable = append(removable, r)
+		}
+	}
+
+	var removed []string
+	if len(removable) > keep {
+		for _, r := range removable[:len(removable)-keep] {
+			dir := filepath.Join(ReleasesDir(installDir), r.Version)
+			if err := ex.RemoveAll(dir); err != nil {
+				return removed, fmt.Errorf("failed to remove release %s: %w", r.Version, err)
+			}
+			m.Releases = removeRecord(m.Releases, r.Version)
+			removed = append(removed, r.Version)
+		}
+	}
+
+	if err := saveManifest(ex, installDir, m); err != nil {
+		return removed, err
+	}
+	return removed, nil
+}
+
+// previousVersion returns the version with the highest Seq less than
+// current's, i.e. the release actually installed immediately before
+// current, regardless of where either ended up in Releases.
+func previousVersion(records []Record, current string) (string, error) {
+	currentSeq := -1
+	for _, r := range records {
+		if r.Version == current {
+			currentSeq = r.Seq
+			break
+		}
+	}
+	if currentSeq < 0 {
+		return "", fmt.Errorf("no install record for %s", current)
+	}
+
+	best := Record{Seq: -1}
+	for _, r := range records {
+		if r.Version != current && r.Seq < currentSeq && r.Seq > best.Seq {
+			best = r
+		}
+	}
+	if best.Seq < 0 {
+		return "", fmt.Errorf("no release before %s to roll back to", current)
+	}
+	return best.Version, nil
+}
+
+func seqFor(records []Record, version string) int {
+	for _, r := range records {
+		if r.Version == version {
+			return r.Seq
+		}
+	}
+	return 0
+}
+
+func sortedBySeq(records []Record) []Record {
+	out := make([]Record, len(records))
+	copy(out, records)
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+func recordFor(records []Record, version string) Record {
+	for _, r := range records {
+		if r.Version == version {
+			return r
+		}
+	}
+	return Record{Version: version}
+}
+
+func removeRecord(records []Record, version string) []Record {
+	out := records[:0]
+	for _, r := range records {
+		if r.Version != version {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func loadManifest(installDir string) (*manifest, error) {
+	data, err := os.ReadFile(manifestPath(installDir))
+	if os.IsNotExist(err) {
+		return &manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse releases manifest: %w", err)
+	}
+	return &m, nil
+}
+
+func saveManifest(ex executor.Executor, installDir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode releases manifest: %w", err)
+	}
+	if err := ex.WriteFile(manifestPath(installDir), data, 0644); err != nil {
+		return fmt.Errorf("failed to write releases manifest: %w", err)
+	}
+	return nil
+}
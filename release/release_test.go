@@ -0,0 +1,169 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+func TestActivateAndRollback(t *testing.T) {
+	ex := executor.NewReal()
+	installDir := t.TempDir()
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if _, err := Stage(ex, installDir, v); err != nil {
+			t.Fatalf("Stage(%s): %v", v, err)
+		}
+		if err := Activate(ex, installDir, v, "hash-"+v); err != nil {
+			t.Fatalf("Activate(%s): %v", v, err)
+		}
+	}
+
+	current, err := CurrentVersion(installDir)
+	if err != nil {
+		t.Fatalf("CurrentVersion: %v", err)
+	}
+	if current != "v3" {
+		t.Fatalf("CurrentVersion = %q, want v3", current)
+	}
+
+	rolledTo, err := Rollback(ex, installDir, "")
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if rolledTo != "v2" {
+		t.Fatalf("Rollback() = %q, want v2", rolledTo)
+	}
+
+	current, err = CurrentVersion(installDir)
+	if err != nil {
+		t.Fatalf("CurrentVersion after rollback: %v", err)
+	}
+	if current != "v2" {
+		t.Fatalf("CurrentVersion after rollback = %q, want v2", current)
+	}
+}
+
+func TestSecondConsecutiveRollbackContinuesBackward(t *testing.T) {
+	ex := executor.NewReal()
+	installDir := t.TempDir()
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if _, err := Stage(ex, installDir, v); err != nil {
+			t.Fatalf("Stage(%s): %v", v, err)
+		}
+		if err := Activate(ex, installDir, v, "hash-"+v); err != nil {
+			t.Fatalf("Activate(%s): %v", v, err)
+		}
+	}
+
+	if rolledTo, err := Rollback(ex, installDir, ""); err != nil {
+		t.Fatalf("first Rollback: %v", err)
+	} else if rolledTo != "v2" {
+		t.Fatalf("first Rollback() = %q, want v2", rolledTo)
+	}
+
+	rolledTo, err := Rollback(ex, installDir, "")
+	if err != nil {
+		t.Fatalf("second Rollback: %v", err)
+	}
+	if rolledTo != "v1" {
+		t.Fatalf("second Rollback() = %q, want v1 (not v3, the release just rolled back from)", rolledTo)
+	}
+
+	current, err := CurrentVersion(installDir)
+	if err != nil {
+		t.Fatalf("CurrentVersion: %v", err)
+	}
+	if current != "v1" {
+		t.Fatalf("CurrentVersion after second rollback = %q, want v1", current)
+	}
+
+	if _, err := Rollback(ex, installDir, ""); err == nil {
+		t.Fatal("third Rollback() should fail: there is nothing before v1 to roll back to")
+	}
+}
+
+func TestRollbackToExplicitVersion(t *testing.T) {
+	ex := executor.NewReal()
+	installDir := t.TempDir()
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		if _, err := Stage(ex, installDir, v); err != nil {
+			t.Fatalf("Stage(%s): %v", v, err)
+		}
+		if err := Activate(ex, installDir, v, ""); err != nil {
+			t.Fatalf("Activate(%s): %v", v, err)
+		}
+	}
+
+	if _, err := Rollback(ex, installDir, "v1"); err != nil {
+		t.Fatalf("Rollback(--to v1): %v", err)
+	}
+	current, err := CurrentVersion(installDir)
+	if err != nil {
+		t.Fatalf("CurrentVersion: %v", err)
+	}
+	if current != "v1" {
+		t.Fatalf("CurrentVersion = %q, want v1", current)
+	}
+}
+
+func TestRollbackWithNoPreviousRelease(t *testing.T) {
+	ex := executor.NewReal()
+	installDir := t.TempDir()
+
+	if _, err := Stage(ex, installDir, "v1"); err != nil {
+		t.Fatalf("Stage: %v", err)
+	}
+	if err := Activate(ex, installDir, "v1", ""); err != nil {
+		t.Fatalf("Activate: %v", err)
+	}
+
+	if _, err := Rollback(ex, installDir, ""); err == nil {
+		t.Fatal("Rollback() with only one release should fail, got nil error")
+	}
+}
+
+func TestPruneKeepsCurrentAndNewest(t *testing.T) {
+	ex := executor.NewReal()
+	installDir := t.TempDir()
+
+	for _, v := range []string{"v1", "v2", "v3", "v4"} {
+		if _, err := Stage(ex, installDir, v); err != nil {
+			t.Fatalf("Stage(%s): %v", v, err)
+		}
+		if err := Activate(ex, installDir, v, ""); err != nil {
+			t.Fatalf("Activate(%s): %v", v, err)
+		}
+	}
+	// v4 is current; roll back to v2 so v3/v4 both exist as non-current releases.
+	if _, err := Rollback(ex, installDir, "v2"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	removed, err := Prune(ex, installDir, 1)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+
+	for _, v := range removed {
+		if v == "v2" {
+			t.Fatalf("Prune removed the current release v2: %v", removed)
+		}
+	}
+
+	remaining, err := List(installDir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("List() after prune = %d releases, want 2 (current + keep=1): %v", len(remaining), remaining)
+	}
+
+	if _, err := os.Stat(filepath.Join(ReleasesDir(installDir), "v2")); err != nil {
+		t.Fatalf("current release v2 directory was removed by Prune: %v", err)
+	}
+}
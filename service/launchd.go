@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+const (
+	launchDaemonsDir = "/Library/LaunchDaemons/"
+	launchAgentsDir  = "Library/LaunchAgents/"
+)
+
+// Launchd drives services through launchctl, writing plists to
+// /Library/LaunchDaemons for system-wide services or
+// ~/Library/LaunchAgents when RunAsUser is set. This is the backend
+// picked on macOS hosts.
+type Launchd struct {
+	ex executor.Executor
+}
+
+func NewLaunchd(ex executor.Executor) *Launchd {
+	return &Launchd{ex: ex}
+}
+
+func (l *Launchd) Install(unit UnitSpec) error {
+	path, err := l.plistPath(unit.Name, unit.RunAsUser)
+	if err != nil {
+		return err
+	}
+	if err := l.ex.WriteFile(path, []byte(l.generatePlist(unit)), 0644); err != nil {
+		return fmt.Errorf("failed to write launchd plist: %w", err)
+	}
+	return l.run("load", "-w", path)
+}
+
+func (l *Launchd) Uninstall(name string, runAsUser bool) error {
+	path, err := l.plistPath(name, runAsUser)
+	if err != nil {
+		return err
+	}
+	_ = l.run("unload", "-w", path)
+	return l.ex.Remove(path)
+}
+
+func (l *Launchd) Start(name string, runAsUser bool) error {
+	return l.run("start", l.label(name))
+}
+
+func (l *Launchd) Stop(name string, runAsUser bool) error {
+	return l.run("stop", l.label(name))
+}
+
+func (l *Launchd) Restart(name string, runAsUser bool) error {
+	if err := l.Stop(name, runAsUser); err != nil {
+		return err
+	}
+	return l.Start(name, runAsUser)
+}
+
+func (l *Launchd) Status(name string, runAsUser bool) error {
+	return l.run("list", l.label(name))
+}
+
+func (l *Launchd) run(args ...string) error {
+	if err := l.ex.RunCommand("launchctl", args...); err != nil {
+		return fmt.Errorf("launchctl %v failed: %w", args, err)
+	}
+	return nil
+}
+
+func (l *Launchd) label(name string) string {
+	return "com.quickpackage." + name
+}
+
+func (l *Launchd) plistPath(name string, runAsUser bool) (string, error) {
+	if !runAsUser {
+		return launchDaemonsDir + l.label(name) + ".plist", nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory for user agent: %w", err)
+	}
+	return home + "/" + launchAgentsDir + l.label(name) + ".plist", nil
+}
+
+func (l *Launchd) generatePlist(unit UnitSpec) string {
+	keepAlive := "false"
+	if unit.RestartPolicy == "" || unit.RestartPolicy == "always" {
+		keepAlive = "true"
+	}
+
+	var environment string
+	if len(unit.Environment) > 0 {
+		environment = "<key>EnvironmentVariables</key>\n\t<dict>\n"
+		for _, k := range sortedEnvKeys(unit.Environment) {
+			environment += fmt.Sprintf("\t\t<key>%s</key>\n\t\t<string>%s</string>\n", k, unit.Environment[k])
+		}
+		environment += "\t</dict>\n"
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+%s	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>KeepAlive</key>
+	<%s/>
+	%s</dict>
+</plist>
+`, l.label(unit.Name), l.programArguments(unit), unit.WorkingDirectory, keepAlive, environment)
+}
+
+// programArguments splits ExecPath into the argv launchd expects, the
+// same way systemd's ExecStart= line is whitespace-split by systemd
+// itself, so a config's exec value behaves the same on both backends.
+func (l *Launchd) programArguments(unit UnitSpec) string {
+	var b strings.Builder
+	for _, arg := range strings.Fields(unit.ExecPath) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", arg)
+	}
+	return b.String()
+}
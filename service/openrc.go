@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+const openrcInitDir = "/etc/init.d/"
+
+// OpenRC drives services through rc-service/rc-update and an init.d
+// shell script. It is the fallback backend on Linux hosts that are not
+// running systemd.
+type OpenRC struct {
+	ex executor.Executor
+}
+
+func NewOpenRC(ex executor.Executor) *OpenRC {
+	return &OpenRC{ex: ex}
+}
+
+func (o *OpenRC) Install(unit UnitSpec) error {
+	path := o.scriptPath(unit.Name)
+	if err := o.ex.WriteFile(path, []byte(o.generateScript(unit)), 0755); err != nil {
+		return fmt.Errorf("failed to write init.d script: %w", err)
+	}
+	return o.run("rc-update", "add", unit.Name, "default")
+}
+
+// OpenRC has no notion of a per-user service manager, so runAsUser is
+// accepted only to satisfy ServiceManager and is otherwise ignored.
+
+func (o *OpenRC) Uninstall(name string, runAsUser bool) error {
+	_ = o.run("rc-update", "del", name, "default")
+	return o.ex.Remove(o.scriptPath(name))
+}
+
+func (o *OpenRC) Start(name string, runAsUser bool) error {
+	return o.run("rc-service", name, "start")
+}
+
+func (o *OpenRC) Stop(name string, runAsUser bool) error {
+	return o.run("rc-service", name, "stop")
+}
+
+func (o *OpenRC) Restart(name string, runAsUser bool) error {
+	return o.run("rc-service", name, "restart")
+}
+
+func (o *OpenRC) Status(name string, runAsUser bool) error {
+	return o.run("rc-service", name, "status")
+}
+
+func (o *OpenRC) run(name string, args ...string) error {
+	if err := o.ex.RunCommand(name, args...); err != nil {
+		return fmt.Errorf("%s %v failed: %w", name, args, err)
+	}
+	return nil
+}
+
+func (o *OpenRC) scriptPath(name string) string {
+	return openrcInitDir + name
+}
+
+func (o *OpenRC) generateScript(unit UnitSpec) string {
+	respawn := ""
+	if unit.RestartPolicy == "" || unit.RestartPolicy == "always" {
+		respawn = fmt.Sprintf("%s_respawn=\"yes\"", unit.Name)
+	}
+
+	command, commandArgs := o.command(unit)
+	commandArgsLine := ""
+	if commandArgs != "" {
+		commandArgsLine = fmt.Sprintf("command_args=\"%s\"\n", commandArgs)
+	}
+
+	return fmt.Sprintf(`#!/sbin/openrc-run
+
+name="%s"
+command="%s"
+%scommand_background="yes"
+directory="%s"
+pidfile="/run/${RC_SVCNAME}.pid"
+%s%s
+
+depend() {
+	need net
+}
+`, unit.Name, command, commandArgsLine, unit.WorkingDirectory, respawn, o.environment(unit))
+}
+
+// command splits ExecPath into the binary and its arguments, the same
+// way launchd's programArguments splits it into argv, so OpenRC's
+// command= (which must be a bare binary path) doesn't get handed a
+// string containing arguments.
+func (o *OpenRC) command(unit UnitSpec) (command, args string) {
+	fields := strings.Fields(unit.ExecPath)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+// environment renders export lines for unit.Environment, so the same
+// config produces equivalent environments whether it ends up on
+// systemd, launchd, or OpenRC.
+func (o *OpenRC) environment(unit UnitSpec) string {
+	if len(unit.Environment) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, k := range sortedEnvKeys(unit.Environment) {
+		fmt.Fprintf(&b, "export %s=\"%s\"\n", k, unit.Environment[k])
+	}
+	return "\n" + b.String()
+}
@@ -0,0 +1,5 @@
+//go:build windows
+
+package service
+
+func isWindows() bool { return true }
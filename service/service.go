@@ -0,0 +1,164 @@
+package service
+
+import (
+	"os"
+	"sort"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+// UnitSpec describes a service to be installed, independent of the
+// backend that ends up running it.
+type UnitSpec struct {
+	Name             string
+	ExecPath         string
+	WorkingDirectory string
+	RunAsUser        bool
+
+	// ServiceType mirrors the systemd notion of a unit's startup
+	// behaviour ("simple", "forking", "oneshot") and is mapped onto
+	// whatever the underlying backend understands.
+	ServiceType string
+
+	// RestartPolicy controls whether the service manager should
+	// restart the process after it exits (e.g. "always", "on-failure",
+	// "no").
+	RestartPolicy string
+
+	Environment map[string]string
+
+	// EnvironmentFile names an EnvironmentFile= to load variables from,
+	// in addition to Environment.
+	EnvironmentFile string
+
+	// RestartSec is how long the service manager waits before applying
+	// RestartPolicy.
+	RestartSec string
+
+	// ExecStartPre, ExecStartPost and ExecStop name extra commands to
+	// run around the unit's lifecycle, honoured by backends that
+	// support it (systemd only, currently).
+	ExecStartPre  []string
+	ExecStartPost []string
+	ExecStop      []string
+
+	// After, Requires, Wants, Before and PartOf describe this unit's
+	// ordering and dependency relationship to other units.
+	After    []string
+	Requires []string
+	Wants    []string
+	Before   []string
+	PartOf   []string
+
+	// MemoryMax, CPUQuota and TasksMax set resource limits understood
+	// by backends with cgroup-based accounting (systemd only).
+	MemoryMax string
+	CPUQuota  string
+	TasksMax  string
+
+	// ProtectSystem, NoNewPrivileges and PrivateTmp are sandboxing
+	// hardening flags, honoured by backends that support it (systemd
+	// only, currently). ProtectSystem mirrors systemd's own values:
+	// "", "true", "full" or "strict".
+	ProtectSystem   string
+	NoNewPrivileges bool
+	PrivateTmp      bool
+
+	// Socket and Timer, when set, ask the backend to install a
+	// companion socket-activation or scheduled-timer unit alongside
+	// the main service (systemd only, currently).
+	Socket *SocketSpec
+	Timer  *TimerSpec
+}
+
+// SocketSpec describes a companion .socket unit for socket activation.
+type SocketSpec struct {
+	ListenStream   []string
+	ListenDatagram []string
+	Accept         bool
+}
+
+// TimerSpec describes a companion .timer unit for scheduled activation.
+type TimerSpec struct {
+	OnCalendar string
+	OnBootSec  string
+	Persistent bool
+}
+
+// ServiceManager is implemented by each supported service backend
+// (systemd, launchd, Windows SCM, OpenRC). QuickPackage picks one at
+// runtime via Detect and drives it through this interface so the rest
+// of the codebase never has to know which init system it is talking to.
+type ServiceManager interface {
+	Install(unit UnitSpec) error
+
+	// Uninstall, Start, Stop, Restart and Status all take runAsUser
+	// alongside name so a backend that writes a different unit
+	// identity/path for user-mode services (systemd's "name@" wildcard,
+	// launchd's LaunchAgents plist) resolves the same one Install used,
+	// instead of silently operating on a system-level unit that was
+	// never created.
+	Uninstall(name string, runAsUser bool) error
+	Start(name string, runAsUser bool) error
+	Stop(name string, runAsUser bool) error
+	Restart(name string, runAsUser bool) error
+	Status(name string, runAsUser bool) error
+}
+
+// Detect picks the ServiceManager appropriate for the host platform,
+// mirroring the probing order used by kardianos/service: Windows SCM on
+// Windows; elsewhere check for a running systemd first, then
+// launchctl, then fall back to OpenRC.
+//
+// ex is threaded through to the backends that shell out (systemd,
+// launchd, OpenRC), so a Recording executor makes every command they'd
+// run show up as a plan line instead of actually running, with no
+// separate dry-run branching required of the caller.
+func Detect(ex executor.Executor) ServiceManager {
+	if isWindows() {
+		return NewWindowsSCM()
+	}
+	if hasSystemdProbe() {
+		return NewSystemd(ex)
+	}
+	if hasLaunchdProbe() {
+		return NewLaunchd(ex)
+	}
+	return NewOpenRC(ex)
+}
+
+// hasSystemdProbe and hasLaunchdProbe are indirected through package
+// vars, rather than called directly, so tests can substitute fakes to
+// exercise Detect's probing order without depending on the test host's
+// actual init system.
+var (
+	hasSystemdProbe = hasSystemd
+	hasLaunchdProbe = hasLaunchd
+)
+
+func hasSystemd() bool {
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+func hasLaunchd() bool {
+	for _, dir := range []string{"/bin", "/usr/bin"} {
+		if _, err := os.Stat(dir + "/launchctl"); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedEnvKeys returns env's keys in sorted order, so backends render
+// Environment deterministically instead of in Go's randomized map
+// iteration order — otherwise the same config would render differently
+// across runs, which breaks plan mode's byte-for-byte unit diffing.
+func sortedEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
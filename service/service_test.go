@@ -0,0 +1,256 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+// actionContaining returns the first recorded action containing substr,
+// failing the test if none matches.
+func actionContaining(t *testing.T, actions []string, substr string) string {
+	t.Helper()
+	for _, action := range actions {
+		if strings.Contains(action, substr) {
+			return action
+		}
+	}
+	t.Fatalf("no recorded action contains %q, got: %v", substr, actions)
+	return ""
+}
+
+func TestSystemdGenerateUnitRendersSortedEnvironment(t *testing.T) {
+	unit := UnitSpec{
+		Name:             "demo",
+		ExecPath:         "/opt/demo/bin/demo",
+		WorkingDirectory: "/opt/demo",
+		Environment:      map[string]string{"ZETA": "1", "ALPHA": "2"},
+	}
+
+	rendered := (&Systemd{}).generateUnit(unit)
+
+	zeta := strings.Index(rendered, "Environment=ZETA=1")
+	alpha := strings.Index(rendered, "Environment=ALPHA=2")
+	if zeta < 0 || alpha < 0 {
+		t.Fatalf("generateUnit did not render both Environment= lines:\n%s", rendered)
+	}
+	if alpha > zeta {
+		t.Errorf("generateUnit rendered ALPHA after ZETA, want sorted key order:\n%s", rendered)
+	}
+}
+
+func TestSystemdGenerateUnitOmitsSocketAndTimerByDefault(t *testing.T) {
+	rendered := (&Systemd{}).generateUnit(UnitSpec{Name: "demo", ExecPath: "/opt/demo/bin/demo"})
+	if !strings.Contains(rendered, "[Service]") {
+		t.Errorf("generateUnit did not render a [Service] section:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "WantedBy=multi-user.target") {
+		t.Errorf("generateUnit did not render [Install] WantedBy:\n%s", rendered)
+	}
+	if strings.Contains(rendered, "[Socket]") || strings.Contains(rendered, "[Timer]") {
+		t.Errorf("generateUnit rendered a [Socket]/[Timer] section for a unit without Socket/Timer:\n%s", rendered)
+	}
+}
+
+func TestSystemdGenerateSocketAndTimer(t *testing.T) {
+	unit := UnitSpec{
+		Name: "demo",
+		Socket: &SocketSpec{
+			ListenStream: []string{"127.0.0.1:8080"},
+			Accept:       true,
+		},
+		Timer: &TimerSpec{OnCalendar: "daily", Persistent: true},
+	}
+
+	socket := (&Systemd{}).generateSocket(unit)
+	if !strings.Contains(socket, "ListenStream=127.0.0.1:8080") || !strings.Contains(socket, "Accept=yes") {
+		t.Errorf("generateSocket did not render listen address/accept:\n%s", socket)
+	}
+
+	timer := (&Systemd{}).generateTimer(unit)
+	if !strings.Contains(timer, "OnCalendar=daily") || !strings.Contains(timer, "Persistent=true") {
+		t.Errorf("generateTimer did not render schedule/persistent:\n%s", timer)
+	}
+}
+
+func TestLaunchdGeneratePlistSplitsExecPathIntoArgv(t *testing.T) {
+	unit := UnitSpec{
+		Name:             "demo",
+		ExecPath:         "/opt/demo/bin/demo --flag value",
+		WorkingDirectory: "/opt/demo",
+		Environment:      map[string]string{"ZETA": "1", "ALPHA": "2"},
+	}
+
+	rendered := (&Launchd{}).generatePlist(unit)
+
+	for _, arg := range []string{"/opt/demo/bin/demo", "--flag", "value"} {
+		if !strings.Contains(rendered, "<string>"+arg+"</string>") {
+			t.Errorf("generatePlist did not split ExecPath into argv, missing %q:\n%s", arg, rendered)
+		}
+	}
+
+	zeta := strings.Index(rendered, "<key>ZETA</key>")
+	alpha := strings.Index(rendered, "<key>ALPHA</key>")
+	if zeta < 0 || alpha < 0 {
+		t.Fatalf("generatePlist did not render both environment keys:\n%s", rendered)
+	}
+	if alpha > zeta {
+		t.Errorf("generatePlist rendered ALPHA after ZETA, want sorted key order:\n%s", rendered)
+	}
+}
+
+func TestOpenRCGenerateScriptRendersEnvironment(t *testing.T) {
+	unit := UnitSpec{
+		Name:             "demo",
+		ExecPath:         "/opt/demo/bin/demo",
+		WorkingDirectory: "/opt/demo",
+		Environment:      map[string]string{"ZETA": "1", "ALPHA": "2"},
+	}
+
+	rendered := (&OpenRC{}).generateScript(unit)
+
+	if !strings.Contains(rendered, `command="/opt/demo/bin/demo"`) {
+		t.Errorf("generateScript did not render command=:\n%s", rendered)
+	}
+
+	zeta := strings.Index(rendered, `export ZETA="1"`)
+	alpha := strings.Index(rendered, `export ALPHA="2"`)
+	if zeta < 0 || alpha < 0 {
+		t.Fatalf("generateScript did not render both export lines:\n%s", rendered)
+	}
+	if alpha > zeta {
+		t.Errorf("generateScript rendered ALPHA after ZETA, want sorted key order:\n%s", rendered)
+	}
+}
+
+func TestOpenRCGenerateScriptSplitsExecPathArgs(t *testing.T) {
+	unit := UnitSpec{
+		Name:             "demo",
+		ExecPath:         "/opt/demo/bin/demo --flag value",
+		WorkingDirectory: "/opt/demo",
+	}
+
+	rendered := (&OpenRC{}).generateScript(unit)
+
+	if !strings.Contains(rendered, `command="/opt/demo/bin/demo"`) {
+		t.Errorf("generateScript did not render bare command=:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, `command_args="--flag value"`) {
+		t.Errorf("generateScript did not render command_args=:\n%s", rendered)
+	}
+}
+
+func TestRenderSystemdSocketAndTimerMatchPathHelpers(t *testing.T) {
+	unit := UnitSpec{
+		Name:   "demo",
+		Socket: &SocketSpec{ListenStream: []string{"127.0.0.1:8080"}},
+		Timer:  &TimerSpec{OnCalendar: "daily"},
+	}
+
+	if got, want := RenderSystemdSocket(unit), (&Systemd{}).generateSocket(unit); got != want {
+		t.Errorf("RenderSystemdSocket(unit) = %q, want %q", got, want)
+	}
+	if got, want := RenderSystemdTimer(unit), (&Systemd{}).generateTimer(unit); got != want {
+		t.Errorf("RenderSystemdTimer(unit) = %q, want %q", got, want)
+	}
+	if got, want := SystemdSocketPath(unit.Name), systemdUnitDir+"demo.socket"; got != want {
+		t.Errorf("SystemdSocketPath(%q) = %q, want %q", unit.Name, got, want)
+	}
+	if got, want := SystemdTimerPath(unit.Name), systemdUnitDir+"demo.timer"; got != want {
+		t.Errorf("SystemdTimerPath(%q) = %q, want %q", unit.Name, got, want)
+	}
+}
+
+func TestSystemdLifecycleTargetsSameUnitInstallUsed(t *testing.T) {
+	for _, runAsUser := range []bool{false, true} {
+		t.Run(map[bool]string{false: "system", true: "user"}[runAsUser], func(t *testing.T) {
+			rec := executor.NewRecording()
+			s := NewSystemd(rec)
+			unit := UnitSpec{Name: "demo", ExecPath: "/opt/demo/bin/demo", RunAsUser: runAsUser}
+
+			if err := s.Install(unit); err != nil {
+				t.Fatalf("Install() = %v", err)
+			}
+			if err := s.Start(unit.Name, runAsUser); err != nil {
+				t.Fatalf("Start() = %v", err)
+			}
+			if err := s.Stop(unit.Name, runAsUser); err != nil {
+				t.Fatalf("Stop() = %v", err)
+			}
+			if err := s.Uninstall(unit.Name, runAsUser); err != nil {
+				t.Fatalf("Uninstall() = %v", err)
+			}
+
+			wantPath := s.unitPath(unit.Name, runAsUser)
+			wantWildcard := s.unitNameWildcard(unit.Name, runAsUser)
+
+			actionContaining(t, rec.Actions, "write "+wantPath)
+			actionContaining(t, rec.Actions, "exec systemctl [start "+wantWildcard+"]")
+			actionContaining(t, rec.Actions, "exec systemctl [stop "+wantWildcard+"]")
+			actionContaining(t, rec.Actions, "remove "+wantPath)
+		})
+	}
+}
+
+func TestLaunchdLifecycleTargetsSamePlistInstallUsed(t *testing.T) {
+	for _, runAsUser := range []bool{false, true} {
+		t.Run(map[bool]string{false: "daemon", true: "agent"}[runAsUser], func(t *testing.T) {
+			rec := executor.NewRecording()
+			l := NewLaunchd(rec)
+			unit := UnitSpec{Name: "demo", ExecPath: "/opt/demo/bin/demo", RunAsUser: runAsUser}
+
+			if err := l.Install(unit); err != nil {
+				t.Fatalf("Install() = %v", err)
+			}
+			if err := l.Uninstall(unit.Name, runAsUser); err != nil {
+				t.Fatalf("Uninstall() = %v", err)
+			}
+
+			wantPath, err := l.plistPath(unit.Name, runAsUser)
+			if err != nil {
+				t.Fatalf("plistPath() = %v", err)
+			}
+			if runAsUser != strings.Contains(wantPath, launchAgentsDir) {
+				t.Fatalf("plistPath(%v) = %q, want it to reflect runAsUser", runAsUser, wantPath)
+			}
+
+			actionContaining(t, rec.Actions, "write "+wantPath)
+			actionContaining(t, rec.Actions, "exec launchctl [load -w "+wantPath+"]")
+			actionContaining(t, rec.Actions, "exec launchctl [unload -w "+wantPath+"]")
+			actionContaining(t, rec.Actions, "remove "+wantPath)
+		})
+	}
+}
+
+func TestDetectProbesSystemdBeforeLaunchdBeforeOpenRC(t *testing.T) {
+	origSystemd, origLaunchd := hasSystemdProbe, hasLaunchdProbe
+	defer func() { hasSystemdProbe, hasLaunchdProbe = origSystemd, origLaunchd }()
+
+	t.Run("systemd wins when present", func(t *testing.T) {
+		hasSystemdProbe = func() bool { return true }
+		hasLaunchdProbe = func() bool { return true }
+		sm := Detect(executor.NewRecording())
+		if _, ok := sm.(*Systemd); !ok {
+			t.Errorf("Detect() = %T, want *Systemd when systemd is present", sm)
+		}
+	})
+
+	t.Run("launchd wins when systemd absent", func(t *testing.T) {
+		hasSystemdProbe = func() bool { return false }
+		hasLaunchdProbe = func() bool { return true }
+		sm := Detect(executor.NewRecording())
+		if _, ok := sm.(*Launchd); !ok {
+			t.Errorf("Detect() = %T, want *Launchd when systemd absent but launchd present", sm)
+		}
+	})
+
+	t.Run("falls back to OpenRC when neither present", func(t *testing.T) {
+		hasSystemdProbe = func() bool { return false }
+		hasLaunchdProbe = func() bool { return false }
+		sm := Detect(executor.NewRecording())
+		if _, ok := sm.(*OpenRC); !ok {
+			t.Errorf("Detect() = %T, want *OpenRC when neither systemd nor launchd present", sm)
+		}
+	})
+}
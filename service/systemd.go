@@ -0,0 +1,308 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ElementalMP4/QuickPackage/executor"
+)
+
+const systemdUnitDir = "/usr/lib/systemd/system/"
+
+// Systemd drives services through systemctl, writing unit files to
+// systemdUnitDir. It is the default backend on any host with a running
+// systemd instance.
+type Systemd struct {
+	ex executor.Executor
+}
+
+func NewSystemd(ex executor.Executor) *Systemd {
+	return &Systemd{ex: ex}
+}
+
+// RenderSystemdUnit renders the unit file text for unit without
+// touching the filesystem, for callers (such as the packager
+// subpackage) that need to stage it inside an artifact rather than
+// write it to the host.
+func RenderSystemdUnit(unit UnitSpec) string {
+	return (&Systemd{}).generateUnit(unit)
+}
+
+// SystemdUnitPath returns the path Install would write unit to, for
+// callers (such as plan mode) that want to compare the rendered unit
+// against whatever is already on disk.
+func SystemdUnitPath(name string, runAsUser bool) string {
+	return (&Systemd{}).unitPath(name, runAsUser)
+}
+
+// RenderSystemdSocket renders the companion .socket unit text for unit,
+// for callers (such as plan mode) that want to preview it without
+// touching the filesystem. It panics if unit.Socket is nil; callers
+// must check that first.
+func RenderSystemdSocket(unit UnitSpec) string {
+	return (&Systemd{}).generateSocket(unit)
+}
+
+// SystemdSocketPath returns the path Install would write unit's .socket
+// unit to, for callers (such as plan mode) that want to compare the
+// rendered socket unit against whatever is already on disk.
+func SystemdSocketPath(name string) string {
+	return (&Systemd{}).socketPath(name)
+}
+
+// RenderSystemdTimer renders the companion .timer unit text for unit,
+// for callers (such as plan mode) that want to preview it without
+// touching the filesystem. It panics if unit.Timer is nil; callers
+// must check that first.
+func RenderSystemdTimer(unit UnitSpec) string {
+	return (&Systemd{}).generateTimer(unit)
+}
+
+// SystemdTimerPath returns the path Install would write unit's .timer
+// unit to, for callers (such as plan mode) that want to compare the
+// rendered timer unit against whatever is already on disk.
+func SystemdTimerPath(name string) string {
+	return (&Systemd{}).timerPath(name)
+}
+
+func (s *Systemd) Install(unit UnitSpec) error {
+	if err := s.ex.WriteFile(s.unitPath(unit.Name, unit.RunAsUser), []byte(s.generateUnit(unit)), 0644); err != nil {
+		return fmt.Errorf("failed to write systemd unit: %w", err)
+	}
+
+	if unit.Socket != nil {
+		if err := s.ex.WriteFile(s.socketPath(unit.Name), []byte(s.generateSocket(unit)), 0644); err != nil {
+			return fmt.Errorf("failed to write systemd socket unit: %w", err)
+		}
+	}
+	if unit.Timer != nil {
+		if err := s.ex.WriteFile(s.timerPath(unit.Name), []byte(s.generateTimer(unit)), 0644); err != nil {
+			return fmt.Errorf("failed to write systemd timer unit: %w", err)
+		}
+	}
+
+	if err := s.run("daemon-reload"); err != nil {
+		return err
+	}
+
+	if unit.Socket != nil {
+		if err := s.run("enable", "--now", unit.Name+".socket"); err != nil {
+			return err
+		}
+	}
+	if unit.Timer != nil {
+		if err := s.run("enable", "--now", unit.Name+".timer"); err != nil {
+			return err
+		}
+	}
+
+	// A socket- or timer-activated unit must not also be enabled
+	// resident at boot: the .socket/.timer unit is what starts it,
+	// on demand or on schedule, not multi-user.target.
+	if unit.Socket != nil || unit.Timer != nil {
+		return nil
+	}
+	return s.run("enable", "--now", s.unitNameWildcard(unit.Name, unit.RunAsUser))
+}
+
+func (s *Systemd) Uninstall(name string, runAsUser bool) error {
+	wildcard := s.unitNameWildcard(name, runAsUser)
+	_ = s.run("stop", name+".timer", name+".socket", wildcard)
+	_ = s.run("disable", name+".timer", name+".socket", wildcard)
+	_ = s.ex.Remove(s.unitPath(name, runAsUser))
+	_ = s.ex.Remove(s.socketPath(name))
+	_ = s.ex.Remove(s.timerPath(name))
+	return s.run("daemon-reload")
+}
+
+func (s *Systemd) Start(name string, runAsUser bool) error {
+	return s.run("start", s.unitNameWildcard(name, runAsUser))
+}
+
+func (s *Systemd) Stop(name string, runAsUser bool) error {
+	return s.run("stop", s.unitNameWildcard(name, runAsUser))
+}
+
+func (s *Systemd) Restart(name string, runAsUser bool) error {
+	return s.run("restart", s.unitNameWildcard(name, runAsUser))
+}
+
+func (s *Systemd) Status(name string, runAsUser bool) error {
+	return s.run("is-active", "--quiet", s.unitNameWildcard(name, runAsUser))
+}
+
+func (s *Systemd) run(args ...string) error {
+	if err := s.ex.RunCommand("systemctl", args...); err != nil {
+		return fmt.Errorf("systemctl %s failed: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func (s *Systemd) unitName(name string, runAsUser bool) string {
+	if runAsUser {
+		return name + "@"
+	}
+	return name
+}
+
+func (s *Systemd) unitNameWildcard(name string, runAsUser bool) string {
+	if runAsUser {
+		return s.unitName(name, runAsUser) + "*"
+	}
+	return s.unitName(name, runAsUser)
+}
+
+func (s *Systemd) unitPath(name string, runAsUser bool) string {
+	return systemdUnitDir + s.unitName(name, runAsUser) + ".service"
+}
+
+func (s *Systemd) socketPath(name string) string {
+	return systemdUnitDir + name + ".socket"
+}
+
+func (s *Systemd) timerPath(name string) string {
+	return systemdUnitDir + name + ".timer"
+}
+
+func (s *Systemd) generateUnit(unit UnitSpec) string {
+	description := unit.Name + " service"
+	user := "root"
+	if unit.RunAsUser {
+		description = unit.Name + " service running as user %i"
+		user = "%i"
+	}
+
+	serviceType := unit.ServiceType
+	if serviceType == "" {
+		serviceType = "simple"
+	}
+	restart := unit.RestartPolicy
+	if restart == "" {
+		restart = "always"
+	}
+
+	after := "network.target"
+	if len(unit.After) > 0 {
+		after = strings.Join(unit.After, " ")
+	}
+
+	var unitExtra strings.Builder
+	if len(unit.Requires) > 0 {
+		fmt.Fprintf(&unitExtra, "Requires=%s\n", strings.Join(unit.Requires, " "))
+	}
+	if len(unit.Wants) > 0 {
+		fmt.Fprintf(&unitExtra, "Wants=%s\n", strings.Join(unit.Wants, " "))
+	}
+	if len(unit.Before) > 0 {
+		fmt.Fprintf(&unitExtra, "Before=%s\n", strings.Join(unit.Before, " "))
+	}
+	if len(unit.PartOf) > 0 {
+		fmt.Fprintf(&unitExtra, "PartOf=%s\n", strings.Join(unit.PartOf, " "))
+	}
+
+	var serviceExtra strings.Builder
+	if unit.RestartSec != "" {
+		fmt.Fprintf(&serviceExtra, "RestartSec=%s\n", unit.RestartSec)
+	}
+	if unit.EnvironmentFile != "" {
+		fmt.Fprintf(&serviceExtra, "EnvironmentFile=%s\n", unit.EnvironmentFile)
+	}
+	for _, k := range sortedEnvKeys(unit.Environment) {
+		fmt.Fprintf(&serviceExtra, "Environment=%s=%s\n", k, unit.Environment[k])
+	}
+	for _, cmd := range unit.ExecStartPre {
+		fmt.Fprintf(&serviceExtra, "ExecStartPre=%s\n", cmd)
+	}
+	for _, cmd := range unit.ExecStartPost {
+		fmt.Fprintf(&serviceExtra, "ExecStartPost=%s\n", cmd)
+	}
+	for _, cmd := range unit.ExecStop {
+		fmt.Fprintf(&serviceExtra, "ExecStop=%s\n", cmd)
+	}
+	if unit.MemoryMax != "" {
+		fmt.Fprintf(&serviceExtra, "MemoryMax=%s\n", unit.MemoryMax)
+	}
+	if unit.CPUQuota != "" {
+		fmt.Fprintf(&serviceExtra, "CPUQuota=%s\n", unit.CPUQuota)
+	}
+	if unit.TasksMax != "" {
+		fmt.Fprintf(&serviceExtra, "TasksMax=%s\n", unit.TasksMax)
+	}
+	if unit.ProtectSystem != "" {
+		fmt.Fprintf(&serviceExtra, "ProtectSystem=%s\n", unit.ProtectSystem)
+	}
+	if unit.NoNewPrivileges {
+		serviceExtra.WriteString("NoNewPrivileges=true\n")
+	}
+	if unit.PrivateTmp {
+		serviceExtra.WriteString("PrivateTmp=true\n")
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=%s
+After=%s
+%s
+[Service]
+Type=%s
+ExecStart=%s
+WorkingDirectory=%s
+Restart=%s
+User=%s
+%s
+[Install]
+WantedBy=multi-user.target
+`, description, after, unitExtra.String(), serviceType, unit.ExecPath, unit.WorkingDirectory, restart, user, serviceExtra.String())
+}
+
+// generateSocket renders the companion .socket unit that activates
+// unit on first connection, for units with a non-nil Socket.
+func (s *Systemd) generateSocket(unit UnitSpec) string {
+	var listen strings.Builder
+	for _, addr := range unit.Socket.ListenStream {
+		fmt.Fprintf(&listen, "ListenStream=%s\n", addr)
+	}
+	for _, addr := range unit.Socket.ListenDatagram {
+		fmt.Fprintf(&listen, "ListenDatagram=%s\n", addr)
+	}
+
+	accept := "no"
+	if unit.Socket.Accept {
+		accept = "yes"
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=%s socket
+
+[Socket]
+%sAccept=%s
+
+[Install]
+WantedBy=sockets.target
+`, unit.Name, listen.String(), accept)
+}
+
+// generateTimer renders the companion .timer unit that activates unit
+// on a schedule, for units with a non-nil Timer.
+func (s *Systemd) generateTimer(unit UnitSpec) string {
+	var schedule strings.Builder
+	if unit.Timer.OnCalendar != "" {
+		fmt.Fprintf(&schedule, "OnCalendar=%s\n", unit.Timer.OnCalendar)
+	}
+	if unit.Timer.OnBootSec != "" {
+		fmt.Fprintf(&schedule, "OnBootSec=%s\n", unit.Timer.OnBootSec)
+	}
+	persistent := "false"
+	if unit.Timer.Persistent {
+		persistent = "true"
+	}
+
+	return fmt.Sprintf(`[Unit]
+Description=%s timer
+
+[Timer]
+%sPersistent=%s
+
+[Install]
+WantedBy=timers.target
+`, unit.Name, schedule.String(), persistent)
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package service
+
+import "fmt"
+
+// WindowsSCM is only functional when built for GOOS=windows. Elsewhere
+// it exists so Detect and the rest of the package compile everywhere,
+// but Detect never returns it on a non-Windows host.
+type WindowsSCM struct{}
+
+func NewWindowsSCM() *WindowsSCM {
+	return &WindowsSCM{}
+}
+
+func (w *WindowsSCM) unsupported() error {
+	return fmt.Errorf("windows service control manager is not supported on this platform")
+}
+
+func (w *WindowsSCM) Install(unit UnitSpec) error                 { return w.unsupported() }
+func (w *WindowsSCM) Uninstall(name string, runAsUser bool) error { return w.unsupported() }
+func (w *WindowsSCM) Start(name string, runAsUser bool) error     { return w.unsupported() }
+func (w *WindowsSCM) Stop(name string, runAsUser bool) error      { return w.unsupported() }
+func (w *WindowsSCM) Restart(name string, runAsUser bool) error   { return w.unsupported() }
+func (w *WindowsSCM) Status(name string, runAsUser bool) error    { return w.unsupported() }
@@ -0,0 +1,129 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// WindowsSCM drives services through the Windows Service Control
+// Manager via golang.org/x/sys/windows/svc/mgr.
+//
+// Unlike the other backends, WindowsSCM does not take an
+// executor.Executor and every method below mutates the real service
+// manager unconditionally — there is no Recording-backed dry run for
+// Windows yet. Callers that support --dry-run/plan mode must check for
+// this backend themselves (see main.go's doInstall) and skip calling
+// it rather than relying on it to no-op.
+type WindowsSCM struct{}
+
+func NewWindowsSCM() *WindowsSCM {
+	return &WindowsSCM{}
+}
+
+func (w *WindowsSCM) Install(unit UnitSpec) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	startType := uint32(mgr.StartAutomatic)
+	if unit.ServiceType == "oneshot" {
+		startType = mgr.StartManual
+	}
+
+	s, err := m.CreateService(unit.Name, unit.ExecPath, mgr.Config{
+		DisplayName: unit.Name,
+		StartType:   startType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %w", unit.Name, err)
+	}
+	defer s.Close()
+
+	return s.Start()
+}
+
+func (w *WindowsSCM) Uninstall(name string, runAsUser bool) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	_, _ = s.Control(svc.Stop)
+	return s.Delete()
+}
+
+func (w *WindowsSCM) Start(name string, runAsUser bool) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	return s.Start()
+}
+
+func (w *WindowsSCM) Stop(name string, runAsUser bool) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	_, err = s.Control(svc.Stop)
+	return err
+}
+
+func (w *WindowsSCM) Restart(name string, runAsUser bool) error {
+	if err := w.Stop(name, runAsUser); err != nil {
+		return err
+	}
+	return w.Start(name, runAsUser)
+}
+
+func (w *WindowsSCM) Status(name string, runAsUser bool) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State != svc.Running {
+		return fmt.Errorf("service %s is not running (state=%v)", name, status.State)
+	}
+	return nil
+}
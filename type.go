@@ -2,7 +2,8 @@ package main
 
 import (
 	"fmt"
-	"path/filepath"
+
+	"github.com/ElementalMP4/QuickPackage/service"
 )
 
 type FileEntry struct {
@@ -10,87 +11,131 @@ type FileEntry struct {
 	From string `json:"from"`
 }
 
-type Config struct {
-	AppName          string      `json:"app_name"`
-	BuildFiles       []string    `json:"build_files"`
-	InstallFiles     []FileEntry `json:"install_files"`
-	BuildScript      string      `json:"build_script,omitempty"`
-	InstallScript    string      `json:"install_script,omitempty"`
-	UninstallScript  string      `json:"uninstall_script,omitempty"`
-	Systemd          bool        `json:"systemd"`
-	SystemdRunAsUser bool        `json:"systemdRunAsUser"`
-	Exec             string      `json:"exec,omitempty"`
-}
-
-type SystemdUnit struct {
-	Name      string
-	RunAsUser bool
-	ExecPath  string
+// SocketConfig asks for a companion .socket unit so the service can be
+// started on demand by the first connection instead of at boot.
+type SocketConfig struct {
+	ListenStream   []string `json:"listen_stream,omitempty"`
+	ListenDatagram []string `json:"listen_datagram,omitempty"`
+	Accept         bool     `json:"accept,omitempty"`
 }
 
-func (s *SystemdUnit) GenerateDescription() string {
-	if s.RunAsUser {
-		return s.Name + " service running as user %i"
-	} else {
-		return s.Name + " service"
-	}
-}
-
-func (s *SystemdUnit) GetUser() any {
-	if s.RunAsUser {
-		return "%i"
-	} else {
-		return "root"
-	}
+// TimerConfig asks for a companion .timer unit so the service runs on
+// a schedule instead of staying resident.
+type TimerConfig struct {
+	OnCalendar string `json:"on_calendar,omitempty"`
+	OnBootSec  string `json:"on_boot_sec,omitempty"`
+	Persistent bool   `json:"persistent,omitempty"`
 }
 
-func (s *SystemdUnit) GenerateFile() string {
-	description := s.GenerateDescription()
-	user := s.GetUser()
-	workingDirectory := filepath.Join(InstallPath, s.Name)
-
-	return fmt.Sprintf(`[Unit]
-Description=%s
-After=network.target
-
-[Service]
-Type=simple
-ExecStart=%s
-WorkingDirectory=%s
-Restart=always
-User=%s
-
-[Install]
-WantedBy=multi-user.target
-`, description, s.ExecPath, workingDirectory, user)
+// TargetConfig describes one remote host `quickpackage deploy` can
+// install to. It doubles as the shape of entries in a YAML --inventory
+// file. Entries apply only to their own host: a host named in --hosts
+// with no matching entry gets just its host name and any user@ prefix.
+type TargetConfig struct {
+	Host        string            `json:"host" yaml:"host"`
+	User        string            `json:"user,omitempty" yaml:"user,omitempty"`
+	KeyPath     string            `json:"key_path,omitempty" yaml:"key_path,omitempty"`
+	UseAgent    bool              `json:"use_agent,omitempty" yaml:"use_agent,omitempty"`
+	Become      bool              `json:"become,omitempty" yaml:"become,omitempty"`
+	BecomeUser  string            `json:"become_user,omitempty" yaml:"become_user,omitempty"`
+	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	// BinaryPath overrides the quickpackage binary deploy uploads to
+	// this host, for fleets whose OS/arch differs from the control
+	// host's (e.g. deploying from a macOS laptop to Linux targets).
+	// Path is resolved on the control host, not the target.
+	BinaryPath string `json:"binary_path,omitempty" yaml:"binary_path,omitempty"`
 }
 
-func (s *SystemdUnit) UnitPath() string {
-	return "/usr/lib/systemd/system/" + s.UnitName() + ".service"
+type Config struct {
+	AppName          string            `json:"app_name"`
+	BuildFiles       []string          `json:"build_files"`
+	InstallFiles     []FileEntry       `json:"install_files"`
+	BuildScript      string            `json:"build_script,omitempty"`
+	InstallScript    string            `json:"install_script,omitempty"`
+	UninstallScript  string            `json:"uninstall_script,omitempty"`
+	Systemd          bool              `json:"systemd"`
+	SystemdRunAsUser bool              `json:"systemdRunAsUser"`
+	Exec             string            `json:"exec,omitempty"`
+	ServiceType      string            `json:"service_type,omitempty"`
+	RestartPolicy    string            `json:"restart_policy,omitempty"`
+	RestartSec       string            `json:"restart_sec,omitempty"`
+	Environment      map[string]string `json:"environment,omitempty"`
+	EnvironmentFile  string            `json:"environment_file,omitempty"`
+	ExecStartPre     []string          `json:"exec_start_pre,omitempty"`
+	ExecStartPost    []string          `json:"exec_start_post,omitempty"`
+	ExecStop         []string          `json:"exec_stop,omitempty"`
+	After            []string          `json:"after,omitempty"`
+	Requires         []string          `json:"requires,omitempty"`
+	Wants            []string          `json:"wants,omitempty"`
+	Before           []string          `json:"before,omitempty"`
+	PartOf           []string          `json:"part_of,omitempty"`
+	MemoryMax        string            `json:"memory_max,omitempty"`
+	CPUQuota         string            `json:"cpu_quota,omitempty"`
+	TasksMax         string            `json:"tasks_max,omitempty"`
+	ProtectSystem    string            `json:"protect_system,omitempty"`
+	NoNewPrivileges  bool              `json:"no_new_privileges,omitempty"`
+	PrivateTmp       bool              `json:"private_tmp,omitempty"`
+	Socket           *SocketConfig     `json:"socket,omitempty"`
+	Timer            *TimerConfig      `json:"timer,omitempty"`
+	Targets          []TargetConfig    `json:"targets,omitempty"`
+	Version          string            `json:"version,omitempty"`
+	Maintainer       string            `json:"maintainer,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Depends          []string          `json:"depends,omitempty"`
+	Conflicts        []string          `json:"conflicts,omitempty"`
+	License          string            `json:"license,omitempty"`
+	Architecture     string            `json:"architecture,omitempty"`
 }
 
-func (s *SystemdUnit) UnitNameWildcard() string {
-	if s.RunAsUser {
-		return s.UnitName() + "*"
-	} else {
-		return s.UnitName()
+// UnitFromConfig builds the service.UnitSpec that describes how cfg's
+// app should be run, independent of which ServiceManager backend ends
+// up installing it. workingDirectory is passed in by the caller since
+// it differs between a direct install (the `current` release symlink)
+// and a packaged build (the package's install prefix).
+func UnitFromConfig(c *Config, workingDirectory string) service.UnitSpec {
+	unit := service.UnitSpec{
+		Name:             c.AppName,
+		ExecPath:         c.Exec,
+		WorkingDirectory: workingDirectory,
+		RunAsUser:        c.SystemdRunAsUser,
+		ServiceType:      c.ServiceType,
+		RestartPolicy:    c.RestartPolicy,
+		RestartSec:       c.RestartSec,
+		Environment:      c.Environment,
+		EnvironmentFile:  c.EnvironmentFile,
+		ExecStartPre:     c.ExecStartPre,
+		ExecStartPost:    c.ExecStartPost,
+		ExecStop:         c.ExecStop,
+		After:            c.After,
+		Requires:         c.Requires,
+		Wants:            c.Wants,
+		Before:           c.Before,
+		PartOf:           c.PartOf,
+		MemoryMax:        c.MemoryMax,
+		CPUQuota:         c.CPUQuota,
+		TasksMax:         c.TasksMax,
+		ProtectSystem:    c.ProtectSystem,
+		NoNewPrivileges:  c.NoNewPrivileges,
+		PrivateTmp:       c.PrivateTmp,
 	}
-}
 
-func (s *SystemdUnit) UnitName() string {
-	if s.RunAsUser {
-		return s.Name + "@"
-	} else {
-		return s.Name
+	if c.Socket != nil {
+		unit.Socket = &service.SocketSpec{
+			ListenStream:   c.Socket.ListenStream,
+			ListenDatagram: c.Socket.ListenDatagram,
+			Accept:         c.Socket.Accept,
+		}
 	}
-}
-
-func UnitFromConfig(c *Config) *SystemdUnit {
-	return &SystemdUnit{
-		Name:      c.AppName,
-		ExecPath:  c.Exec,
-		RunAsUser: c.SystemdRunAsUser,
+	if c.Timer != nil {
+		unit.Timer = &service.TimerSpec{
+			OnCalendar: c.Timer.OnCalendar,
+			OnBootSec:  c.Timer.OnBootSec,
+			Persistent: c.Timer.Persistent,
+		}
 	}
+
+	return unit
 }
 
 func (c *Config) GetBuildScript() string {